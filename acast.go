@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// acastFeed is the public feed Acast publishes for every show
+const acastFeed = "https://feeds.acast.com/public/shows/%s"
+
+// AcastParser implements the parser interface for an Acast show, the string
+// is the url of the show such as https://shows.acast.com/<slug>. The episodes
+// are read from the public feed of the show rather than from its pages, use
+// the scrape type for shows that are only on a page.
+type AcastParser string
+
+// acastSlug returns the slug of the show in an Acast url. It is the first
+// part of the path of shows.acast.com, embed.acast.com and feeds.acast.com
+// urls, and the part after /s/ on play.acast.com and acast.com.
+func acastSlug(u *url.URL) (string, bool) {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch host {
+	case "shows.acast.com", "embed.acast.com":
+	case "feeds.acast.com":
+		if len(parts) < 3 || parts[0] != "public" || parts[1] != "shows" {
+			return "", false
+		}
+		parts = parts[2:]
+	case "play.acast.com", "acast.com":
+		if len(parts) < 2 || parts[0] != "s" {
+			return "", false
+		}
+		parts = parts[1:]
+	default:
+		return "", false
+	}
+	if parts[0] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// URLs fetches the public feed of the show and extracts at most limit
+// media-links from it
+func (ap AcastParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	u, err := url.Parse(string(ap))
+	if err != nil {
+		return fetchResult{}, err
+	}
+	slug, ok := acastSlug(u)
+	if !ok {
+		return fetchResult{}, fmt.Errorf("no acast show in %s", ap)
+	}
+	feed := fmt.Sprintf(acastFeed, url.PathEscape(slug))
+	body, res, err := fetchStream(ctx, feed, v.header(feed))
+	if err != nil {
+		return res, err
+	}
+	defer body.Close()
+	// the feed moving is not the show moving
+	res.movedTo = ""
+	res.eps, res.meta, err = parseRss(body, limit)
+	return res, err
+}
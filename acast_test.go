@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// acastServer answers for feeds.acast.com with the feed of every show in
+// testdata named acast-<slug>.rss
+func acastServer(t *testing.T) {
+	serveHosts(t, func(w http.ResponseWriter, r *http.Request) {
+		slug, ok := strings.CutPrefix(r.URL.Path, "/public/shows/")
+		if r.Header.Get("X-Forwarded-Host") != "feeds.acast.com" || !ok || strings.Contains(slug, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "testdata/acast-"+slug+".rss")
+	})
+}
+
+func TestAcastChannels(t *testing.T) {
+	acastServer(t)
+	tests := []struct {
+		url    string
+		title  string
+		titles []string
+	}{
+		{"https://shows.acast.com/filipandfredrik", "Filip & Fredrik podcast",
+			[]string{"Ett avsnitt med Filip", "Det första avsnittet"}},
+		{"https://play.acast.com/s/alexochsigge", "Alex & Sigges podcast",
+			[]string{"Ett avsnitt med Alex", "Det första avsnittet"}},
+	}
+	for _, tt := range tests {
+		pod := newPod(Config{Name: "Test", URL: tt.url, Type: "acast"})
+		eps, meta, err := pod.fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		if got := titles(eps); !reflect.DeepEqual(got, tt.titles) {
+			t.Errorf("%s: got %q, want %q", tt.url, got, tt.titles)
+		}
+		if meta.Title != tt.title {
+			t.Errorf("%s: got title %q, want %q", tt.url, meta.Title, tt.title)
+		}
+		if pod.url != tt.url {
+			t.Errorf("%s: the url changed to %s", tt.url, pod.url)
+		}
+	}
+}
+
+func TestAcastUnknownShow(t *testing.T) {
+	acastServer(t)
+	pod := newPod(Config{Name: "Test", URL: "https://shows.acast.com/finnsinte", Type: "acast"})
+	_, _, err := pod.fetch(context.Background())
+	var se *statusError
+	if !errors.As(err, &se) || se.code != http.StatusNotFound {
+		t.Errorf("got %v, want a 404", err)
+	}
+}
+
+func TestAcastFeed(t *testing.T) {
+	// acast-kodsnack.rss is a feed as feeds.acast.com serves it, all of the
+	// channel on one line and the acast elements left in
+	acastServer(t)
+	pod := newPod(Config{Name: "Test", URL: "https://www.acast.com/s/kodsnack", Type: "acast"})
+	eps, meta, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := titles(eps), []string{"Felet var i kompilatorn", "En bra abstraktion", "Trailer"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if meta.Title != "Kodsnack" || meta.Link != "https://kodsnack.se" ||
+		meta.Image != "https://assets.pippa.io/shows/5f4a0c5d3e1b2a0012345678/show-cover.jpg" {
+		t.Errorf("got metadata %+v", meta)
+	}
+	ep := eps[0]
+	if ep.url != "https://sphinx.acast.com/p/open/s/5f4a0c5d3e1b2a0012345678/e/65e6b4c2a1b2c30016aa0572/media.mp3" {
+		t.Errorf("got url %s", ep.url)
+	}
+	if ep.guid != "65e6b4c2a1b2c30016aa0572" || ep.episode != 572 || ep.season != 1 {
+		t.Errorf("got guid %s, episode %d, season %d", ep.guid, ep.episode, ep.season)
+	}
+	if ep.duration != 5025 || ep.length != 80123456 || ep.mediaType != "audio/mpeg" {
+		t.Errorf("got %ds, %d bytes of %s", ep.duration, ep.length, ep.mediaType)
+	}
+	if ep.image != "https://assets.pippa.io/shows/5f4a0c5d3e1b2a0012345678/1709614530-artwork.jpg" {
+		t.Errorf("got image %s", ep.image)
+	}
+	if !strings.HasPrefix(ep.description, "<p>Vi pratar om kompilatorfel") {
+		t.Errorf("got description %q", ep.description)
+	}
+	if got := []int64{eps[1].duration, eps[2].duration}; !reflect.DeepEqual(got, []int64{3732, 65}) {
+		t.Errorf("got durations %v, want [3732 65]", got)
+	}
+}
+
+func TestAcastSlug(t *testing.T) {
+	tests := []struct {
+		url  string
+		slug string
+		ok   bool
+	}{
+		{"https://shows.acast.com/kodsnack", "kodsnack", true},
+		{"https://shows.acast.com/kodsnack/episodes/572", "kodsnack", true},
+		{"https://SHOWS.acast.com/kodsnack/", "kodsnack", true},
+		{"https://embed.acast.com/kodsnack/572", "kodsnack", true},
+		{"https://feeds.acast.com/public/shows/kodsnack", "kodsnack", true},
+		{"https://play.acast.com/s/kodsnack", "kodsnack", true},
+		{"https://play.acast.com/s/kodsnack/572", "kodsnack", true},
+		{"https://acast.com/s/kodsnack", "kodsnack", true},
+		{"https://www.acast.com/s/kodsnack", "kodsnack", true},
+		{"https://www.play.acast.com/s/kodsnack", "kodsnack", true},
+		{"https://shows.acast.com/", "", false},
+		{"https://shows.acast.com", "", false},
+		{"https://acast.com/", "", false},
+		{"https://acast.com/kodsnack", "", false},
+		{"https://acast.com/s/", "", false},
+		{"https://play.acast.com/kodsnack", "", false},
+		{"https://feeds.acast.com/kodsnack", "", false},
+		{"https://feeds.acast.com/public/shows", "", false},
+		{"https://feeds.acast.com/public/podcasts/kodsnack", "", false},
+		{"https://sphinx.acast.com/p/open/s/kodsnack", "", false},
+		{"https://example.com/s/kodsnack", "", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slug, ok := acastSlug(u)
+		if slug != tt.slug || ok != tt.ok {
+			t.Errorf("acastSlug(%s) = %q, %v, want %q, %v", tt.url, slug, ok, tt.slug, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// APIEpisode is an episode in the json api
+type APIEpisode struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	PubDate     string `json:"pub_date,omitempty"` // RFC3339
+	Duration    int64  `json:"duration,omitempty"` // seconds
+	GUID        string `json:"guid,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// APIResponse is a podcast in the json api
+type APIResponse struct {
+	Name       string       `json:"name"`
+	LastUpdate string       `json:"last_update"`
+	Episodes   []APIEpisode `json:"episodes"`
+}
+
+// newAPIResponse converts pod, the caller must hold the store lock
+func newAPIResponse(name string, pod *Pod) APIResponse {
+	ar := APIResponse{Name: name,
+		LastUpdate: pod.lastUpdate.Format(time.RFC3339),
+		Episodes:   make([]APIEpisode, len(pod.eps))}
+	for i, ep := range pod.eps {
+		ar.Episodes[i] = APIEpisode{Title: ep.name,
+			URL:         ep.url,
+			Duration:    ep.duration,
+			GUID:        ep.guid,
+			Description: ep.description}
+		if !ep.pubDate.IsZero() {
+			ar.Episodes[i].PubDate = ep.pubDate.Format(time.RFC3339)
+		}
+	}
+	return ar
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("encoding api response", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(j)
+}
+
+// apiPods serves the same data as the index page
+func apiPods(w http.ResponseWriter, r *http.Request) {
+	data := GetPods()
+	if data == nil {
+		data = []TemplatePod{}
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// feedEpisode and feedPod keep the keys /feed.json had before /api/pods
+type feedEpisode struct {
+	Title string
+	URL   string
+}
+
+type feedPod struct {
+	Name       string
+	LastUpdate string
+	Episodes   []feedEpisode
+}
+
+// feedJSON serves /feed.json in its old format, new fields only go in
+// /api/pods
+func feedJSON(w http.ResponseWriter, r *http.Request) {
+	var data []feedPod
+	for _, tp := range GetPods() {
+		fp := feedPod{Name: tp.Name, LastUpdate: tp.LastUpdate,
+			Episodes: make([]feedEpisode, len(tp.Episodes))}
+		for i, te := range tp.Episodes {
+			fp.Episodes[i] = feedEpisode{Title: te.Title, URL: te.URL}
+		}
+		data = append(data, fp)
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// apiPodcasts serves GET /api/podcasts, GET /api/podcasts/{name},
+// DELETE /api/podcasts/{name} and POST /api/podcasts/{name}/enable
+func apiPodcasts(w http.ResponseWriter, r *http.Request) {
+	// r.URL.Path is already unescaped so "alex%20&%20sigge" works
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/podcasts"), "/")
+	if pod, ok := strings.CutSuffix(name, "/enable"); ok && pod != "" {
+		apiEnable(w, r, pod)
+		return
+	}
+	if r.Method == http.MethodDelete && name != "" {
+		apiRemove(w, r, name)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if name != "" {
+		key := strings.ToLower(name)
+		store.RLock()
+		pod, ok := store.pods[key]
+		var ar APIResponse
+		if ok {
+			ar = newAPIResponse(key, pod)
+		}
+		store.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, ar)
+		return
+	}
+
+	data := []APIResponse{}
+	store.RLock()
+	for name, pod := range store.pods {
+		data = append(data, newAPIResponse(name, pod))
+	}
+	store.RUnlock()
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Name < data[j].Name
+	})
+	writeJSON(w, http.StatusOK, data)
+}
+
+// authorized checks the bearer token of requests that change the pods, when
+// no -token is set every request is allowed
+func authorized(w http.ResponseWriter, r *http.Request) bool {
+	if *token == "" {
+		return true
+	}
+	// compared in constant time so the token can't be guessed byte by byte
+	got := []byte(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare(got, []byte("Bearer "+*token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// apiRemove unsubscribes from the podcast called name
+func apiRemove(w http.ResponseWriter, r *http.Request, name string) {
+	if !authorized(w, r) {
+		return
+	}
+	if !store.Remove(name) {
+		http.NotFound(w, r)
+		return
+	}
+	slog.Info("removed podcast", "podcast", name)
+	unpersist(name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiEnable resets the failures of the podcast called name, so a podcast
+// disabled after failing too often is updated again
+func apiEnable(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(w, r) {
+		return
+	}
+	key := strings.ToLower(name)
+	store.Lock()
+	pod, ok := store.pods[key]
+	var ar APIResponse
+	if ok {
+		pod.enable()
+		ar = newAPIResponse(key, pod)
+	}
+	store.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, ar)
+}
+
+// decodeConfig reads the Config in the body of r, an empty type means the
+// format is detected from the feed with NewPodFromURL, which leaves the feed
+// in fetchCache for the fetch that follows. Errors are written to w, an
+// unknown type with status unknownType.
+func decodeConfig(w http.ResponseWriter, r *http.Request, unknownType int) (Config, bool) {
+	var c Config
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return c, false
+	}
+	if c.Type == "" {
+		c.Type = c.Parser
+	}
+	if c.Type != "" && !knownType(c.Type) {
+		http.Error(w, fmt.Sprintf("unknown type %q", c.Type), unknownType)
+		return c, false
+	}
+	detect := c.Type == ""
+	if detect {
+		// the detected formats are all checked like the feed type
+		c.Type = "feed"
+	}
+	err = c.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return c, false
+	}
+	if detect {
+		found, err := NewPodFromURL(c.URL)
+		if err != nil {
+			slog.Warn("rejected podcast", "podcast", c.Name, "url", c.URL, "err", err)
+			http.Error(w, fmt.Sprintf("detecting the feed format: %s", err.Error()), http.StatusUnprocessableEntity)
+			return c, false
+		}
+		c.Type = found.kind
+	}
+	return c, true
+}
+
+// apiAdd serves POST /api/add which subscribes to a new podcast, the body is a
+// Config and an empty type means the format is detected from the feed
+func apiAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	addPod(w, r, http.StatusUnprocessableEntity)
+}
+
+// addPod subscribes to the podcast in the body of r. The feed is fetched
+// first and a feed that can not be fetched, does not parse as its type or
+// has no episodes is rejected, so typos don't leave empty pods behind.
+func addPod(w http.ResponseWriter, r *http.Request, unknownType int) {
+	if !authorized(w, r) {
+		return
+	}
+
+	c, ok := decodeConfig(w, r, unknownType)
+	if !ok {
+		return
+	}
+	if _, ok := store.Get(c.Name); ok {
+		http.Error(w, "podcast already exists", http.StatusConflict)
+		return
+	}
+
+	pod := newPod(c)
+	pod.added = true
+	eps, meta, err := pod.fetch(r.Context())
+	if err != nil && !isPartial(err) {
+		slog.Warn("rejected podcast", "podcast", pod.name, "url", pod.url, "err", err)
+		http.Error(w, fmt.Sprintf("checking %s feed: %s", c.Type, err.Error()), http.StatusUnprocessableEntity)
+		return
+	}
+	key := strings.ToLower(c.Name)
+	store.Lock()
+	if _, ok := store.pods[key]; ok {
+		store.Unlock()
+		http.Error(w, "podcast already exists", http.StatusConflict)
+		return
+	}
+	pod.record(eps, meta, err, time.Now())
+	store.pods[key] = pod
+	ar := newAPIResponse(key, pod)
+	store.Unlock()
+
+	slog.Info("added podcast", "podcast", pod.name, "url", pod.url)
+	persist(pod)
+	writeJSON(w, http.StatusCreated, ar)
+}
+
+// podsHandler serves POST /pods, which subscribes to a podcast like
+// /api/add, and DELETE /pods/{name} or /pods?name={name}
+func podsHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/pods"), "/")
+	if name == "" && r.Method == http.MethodDelete {
+		name = r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "missing name", http.StatusBadRequest)
+			return
+		}
+	}
+	if name != "" {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		apiRemove(w, r, name)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	addPod(w, r, http.StatusBadRequest)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiServer serves the api routes of main
+func apiServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/add", apiAdd)
+	mux.HandleFunc("/api/podcasts", apiPodcasts)
+	mux.HandleFunc("/api/podcasts/", apiPodcasts)
+	mux.HandleFunc("/pods", podsHandler)
+	mux.HandleFunc("/pods/", podsHandler)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do sends a request with the bearer token tok and returns the status
+func do(t *testing.T, method, u, tok, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != "" {
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestAddAndRemove(t *testing.T) {
+	resetStore(t)
+	old := *token
+	*token = "hemlig"
+	t.Cleanup(func() { *token = old })
+	feed := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, rssFeed(2))
+	})
+	srv := apiServer(t)
+	pod := srv.URL + "/api/podcasts/" + url.PathEscape("Alex & Sigge")
+	pods := srv.URL + "/pods/" + url.PathEscape("Alex & Sigge")
+	add := fmt.Sprintf(`{"name": "Alex & Sigge", "url": %q}`, feed.URL)
+
+	steps := []struct {
+		method, url, token, body string
+		want                     int
+	}{
+		{"POST", srv.URL + "/api/add", "", add, http.StatusUnauthorized},
+		{"POST", srv.URL + "/api/add", "hemlig", add, http.StatusCreated},
+		{"POST", srv.URL + "/api/add", "hemlig", add, http.StatusConflict},
+		{"GET", pod, "", "", http.StatusOK},
+		{"DELETE", pod, "", "", http.StatusUnauthorized},
+		{"DELETE", pod, "hemlig", "", http.StatusNoContent},
+		{"GET", pod, "", "", http.StatusNotFound},
+		{"DELETE", pod, "hemlig", "", http.StatusNotFound},
+		{"POST", srv.URL + "/pods", "", add, http.StatusUnauthorized},
+		{"POST", srv.URL + "/pods", "hemlig", add, http.StatusCreated},
+		{"POST", srv.URL + "/pods", "hemlig", add, http.StatusConflict},
+		{"GET", pods, "", "", http.StatusMethodNotAllowed},
+		{"DELETE", pods, "", "", http.StatusUnauthorized},
+		{"DELETE", pods, "hemlig", "", http.StatusNoContent},
+		{"DELETE", pods, "hemlig", "", http.StatusNotFound},
+		{"POST", srv.URL + "/pods", "hemlig", add, http.StatusCreated},
+		{"DELETE", srv.URL + "/pods", "hemlig", "", http.StatusBadRequest},
+		{"DELETE", srv.URL + "/pods?name=" + url.QueryEscape("alex & sigge"), "hemlig", "", http.StatusNoContent},
+	}
+	for i, s := range steps {
+		if got := do(t, s.method, s.url, s.token, s.body); got != s.want {
+			t.Fatalf("step %d, %s %s: got %d, want %d", i, s.method, s.url, got, s.want)
+		}
+		if i != 1 {
+			continue
+		}
+		if p, ok := store.Get("Alex & Sigge"); !ok || p.kind != "rss" {
+			t.Errorf("step %d: the pod added without a type is not an rss pod", i)
+		}
+	}
+	if n := len(store.All()); n != 0 {
+		t.Errorf("%d pods left after removing the only one", n)
+	}
+}
+
+func TestFeedJSONKeepsOldKeys(t *testing.T) {
+	resetStore(t)
+	pod := newPod(Config{Name: "Kodsnack", URL: "https://example.com/rss", Type: "rss"})
+	pod.lastUpdate = time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	pod.eps = []Episode{{name: "Avsnitt 1", url: "https://example.com/1.mp3", guid: "ep-1"}}
+	store.Add("Kodsnack", pod)
+
+	for path, keys := range map[string][]string{
+		"/feed.json": {"Name", "LastUpdate", "Episodes"},
+		"/api/pods":  {"name", "last_update", "episodes"},
+	} {
+		w := httptest.NewRecorder()
+		if path == "/feed.json" {
+			feedJSON(w, httptest.NewRequest("GET", path, nil))
+		} else {
+			apiPods(w, httptest.NewRequest("GET", path, nil))
+		}
+		var got []map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("%s: got %d pods, want 1", path, len(got))
+		}
+		for _, k := range keys {
+			if _, ok := got[0][k]; !ok {
+				t.Errorf("%s: missing key %q in %s", path, k, w.Body)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	feedJSON(w, httptest.NewRequest("GET", "/feed.json", nil))
+	want := `[{"Name":"kodsnack","LastUpdate":"2020-01-02 03:04","Episodes":[{"Title":"Avsnitt 1","URL":"https://example.com/1.mp3"}]}]`
+	if w.Body.String() != want {
+		t.Errorf("got %s, want %s", w.Body, want)
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	old := *token
+	t.Cleanup(func() { *token = old })
+
+	tests := []struct {
+		token, header string
+		want          bool
+	}{
+		{"", "", true},
+		{"", "Bearer anything", true},
+		{"hemlig", "Bearer hemlig", true},
+		{"hemlig", "", false},
+		{"hemlig", "Bearer hemli", false},
+		{"hemlig", "Bearer hemligt", false},
+		{"hemlig", "hemlig", false},
+	}
+	for _, tt := range tests {
+		*token = tt.token
+		r := httptest.NewRequest("POST", "/api/add", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		if got := authorized(w, r); got != tt.want {
+			t.Errorf("token %q, header %q: got %v, want %v", tt.token, tt.header, got, tt.want)
+		}
+		if !tt.want && w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q, header %q: status %d", tt.token, tt.header, w.Code)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// appleID finds the id of the podcast in an Apple Podcasts url such as
+// https://podcasts.apple.com/se/podcast/kodsnack/id1079357485
+var appleID = regexp.MustCompile(`/id(\d+)`)
+
+// appleLookup is the iTunes Lookup API, it returns the feed of a podcast id
+const appleLookup = "https://itunes.apple.com/lookup?id="
+
+// AppleParser implements the parser interface for Apple Podcasts urls. The
+// rss feed of the podcast is looked up on the first fetch and remembered.
+type AppleParser struct {
+	url string
+	// feed is the url of the feed of the podcast once looked up
+	feed string
+}
+
+// appleLookupResult is the answer of the iTunes Lookup API
+type appleLookupResult struct {
+	Results []struct {
+		FeedURL string `json:"feedUrl"`
+	} `json:"results"`
+}
+
+// applePodcastID returns the podcast id in an Apple Podcasts url
+func applePodcastID(u *url.URL) (string, bool) {
+	host := strings.ToLower(u.Hostname())
+	if host != "podcasts.apple.com" && host != "itunes.apple.com" {
+		return "", false
+	}
+	if m := appleID.FindStringSubmatch(u.Path); m != nil {
+		return m[1], true
+	}
+	if id := u.Query().Get("id"); id != "" {
+		return id, true
+	}
+	return "", false
+}
+
+// URLs extracts at most limit media-links from the feed of the podcast
+func (ap *AppleParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	if ap.feed == "" {
+		feed, err := ap.resolve(ctx)
+		if err != nil {
+			return fetchResult{}, err
+		}
+		ap.feed = feed
+	}
+	bs, res, err := fetchContent(ctx, ap.feed, v.header(ap.feed))
+	if err != nil {
+		return res, err
+	}
+	// the feed moving is not the apple url moving
+	res.movedTo = ""
+	res.eps, res.meta, err = parseFeed(bs, res.contentType, limit)
+	return res, err
+}
+
+// resolve asks the iTunes Lookup API for the feed of the podcast
+func (ap *AppleParser) resolve(ctx context.Context) (string, error) {
+	u, err := url.Parse(ap.url)
+	if err != nil {
+		return "", err
+	}
+	id, ok := applePodcastID(u)
+	if !ok {
+		return "", fmt.Errorf("no podcast id in %s", ap.url)
+	}
+	bs, err := fetch(ctx, appleLookup+url.QueryEscape(id))
+	if err != nil {
+		return "", err
+	}
+	var res appleLookupResult
+	err = json.Unmarshal(bs, &res)
+	if err != nil {
+		return "", fmt.Errorf("looking up podcast %s: %s", id, err.Error())
+	}
+	for _, r := range res.Results {
+		if r.FeedURL != "" {
+			return r.FeedURL, nil
+		}
+	}
+	return "", fmt.Errorf("apple podcasts has no feed for podcast %s", id)
+}
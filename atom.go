@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// AtomFeed is the root of an Atom 1.0 feed
+type AtomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"http://www.w3.org/2005/Atom title"`
+	Subtitle string      `xml:"http://www.w3.org/2005/Atom subtitle"`
+	Links    []AtomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Logo     string      `xml:"http://www.w3.org/2005/Atom logo"`
+	Icon     string      `xml:"http://www.w3.org/2005/Atom icon"`
+	Entries  []AtomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+// AtomEntry represents an individual entry in the feed
+type AtomEntry struct {
+	ID        string     `xml:"http://www.w3.org/2005/Atom id"`
+	Title     string     `xml:"http://www.w3.org/2005/Atom title"`
+	Summary   string     `xml:"http://www.w3.org/2005/Atom summary"`
+	Published time.Time  `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   time.Time  `xml:"http://www.w3.org/2005/Atom updated"`
+	Links     []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Author    AtomPerson `xml:"http://www.w3.org/2005/Atom author"`
+}
+
+// AtomPerson is the author of an entry
+type AtomPerson struct {
+	Name string `xml:"http://www.w3.org/2005/Atom name"`
+}
+
+// AtomLink is a link of an entry, the media is in the one with rel="enclosure"
+type AtomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// enclosure returns the first enclosure link of the entry
+func (ae AtomEntry) enclosure() (AtomLink, bool) {
+	for _, l := range ae.Links {
+		if l.Rel == "enclosure" {
+			return l, true
+		}
+	}
+	return AtomLink{}, false
+}
+
+// AtomParser implements the parser interface and the string is the url for the feed
+type AtomParser string
+
+// URLs extracts at most limit media-links from atom, limit 0 means all of them
+func (ap AtomParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	bs, res, err := fetchContent(ctx, string(ap), v.header(string(ap)))
+	if err != nil {
+		return res, err
+	}
+	res.eps, res.meta, err = parseAtom(bs, limit)
+	return res, err
+}
+
+// metadata returns the feed metadata, the link is the alternate html page
+func (af AtomFeed) metadata() PodMetadata {
+	m := PodMetadata{Title: strings.TrimSpace(af.Title),
+		Description: strings.TrimSpace(af.Subtitle),
+		Image:       firstNonEmpty(af.Logo, af.Icon)}
+	for _, l := range af.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			m.Link = l.Href
+			break
+		}
+	}
+	return m
+}
+
+// parseAtom extracts at most limit episodes and the feed metadata from the
+// atom document in bs
+func parseAtom(bs []byte, limit int) ([]Episode, PodMetadata, error) {
+	atom := AtomFeed{}
+	err := newDecoder(bytes.NewReader(bs)).Decode(&atom)
+	if err != nil {
+		return nil, PodMetadata{}, err
+	}
+
+	eps := make([]Episode, 0, len(atom.Entries))
+	for _, e := range atom.Entries {
+		link, ok := e.enclosure()
+		if !ok || link.Href == "" {
+			continue
+		}
+		published := e.Published
+		if published.IsZero() {
+			published = e.Updated
+		}
+		eps = append(eps, Episode{name: e.Title,
+			url:         link.Href,
+			pubDate:     published,
+			description: strings.TrimSpace(e.Summary),
+			length:      parseLength(link.Length),
+			guid:        strings.TrimSpace(e.ID),
+			author:      strings.TrimSpace(e.Author.Name),
+			mediaType:   link.Type})
+	}
+	return newest(eps, limit), atom.metadata(), nil
+}
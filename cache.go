@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// cachedResponse is a fetched feed body kept by FetchCache
+type cachedResponse struct {
+	body        []byte
+	contentType string
+	fetchedAt   time.Time
+}
+
+// FetchCache keeps fetched bodies by url for a short while, so forcing
+// several updates in a row doesn't hit the feed servers every time
+type FetchCache struct {
+	sync.RWMutex
+	ttl     time.Duration
+	entries map[string]cachedResponse
+}
+
+// NewFetchCache returns an empty cache whose entries go stale after ttl, a
+// ttl of 0 disables the cache
+func NewFetchCache(ttl time.Duration) *FetchCache {
+	return &FetchCache{ttl: ttl, entries: make(map[string]cachedResponse)}
+}
+
+var fetchCache = NewFetchCache(0)
+
+// Get returns the body and content type fetched from url if they are fresh
+func (c *FetchCache) Get(url string) ([]byte, string, bool) {
+	c.RLock()
+	e, ok := c.entries[url]
+	c.RUnlock()
+	if !ok || time.Since(e.fetchedAt) > c.ttl {
+		return nil, "", false
+	}
+	return e.body, e.contentType, true
+}
+
+// Put stores the body fetched from url and drops stale entries
+func (c *FetchCache) Put(url string, body []byte, contentType string) {
+	if c.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	c.Lock()
+	for u, e := range c.entries {
+		if now.Sub(e.fetchedAt) > c.ttl {
+			delete(c.entries, u)
+		}
+	}
+	c.entries[url] = cachedResponse{body: body, contentType: contentType, fetchedAt: now}
+	c.Unlock()
+}
+
+// maxCachedBody is the largest body Tee keeps, larger feeds are fetched
+// again rather than held in memory
+const maxCachedBody = 1 << 20
+
+// Tee returns body, which was fetched from url, reading it through. What is
+// read is put in the cache once body is read to the end, unless it is
+// larger than maxCachedBody.
+func (c *FetchCache) Tee(url string, body io.ReadCloser, contentType string) io.ReadCloser {
+	if c.ttl <= 0 {
+		return body
+	}
+	return &teeBody{ReadCloser: body, cache: c, url: url, contentType: contentType}
+}
+
+// teeBody is a body being read by Tee
+type teeBody struct {
+	io.ReadCloser
+	cache       *FetchCache
+	url         string
+	contentType string
+	buf         []byte
+	// skip is set once the body is too large to keep or has been put
+	skip bool
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if !t.skip {
+		t.buf = append(t.buf, p[:n]...)
+		if len(t.buf) > maxCachedBody {
+			t.buf, t.skip = nil, true
+		}
+	}
+	if err == io.EOF && !t.skip {
+		t.cache.Put(t.url, t.buf, t.contentType)
+		t.buf, t.skip = nil, true
+	}
+	return n, err
+}
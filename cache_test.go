@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setFetchCache makes a cache with ttl the fetchCache for the test
+func setFetchCache(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	old := fetchCache
+	fetchCache = NewFetchCache(ttl)
+	t.Cleanup(func() { fetchCache = old })
+}
+
+func TestFetchCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		sleep time.Duration
+		want  int32
+	}{
+		{"fresh", time.Minute, 0, 1},
+		{"stale", 10 * time.Millisecond, 20 * time.Millisecond, 2},
+		{"disabled", 0, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFetchCache(t, tt.ttl)
+			var n atomic.Int32
+			srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+				n.Add(1)
+				w.Header().Set("Content-Type", "application/rss+xml")
+				fmt.Fprint(w, rssFeed(1))
+			})
+			for i := 0; i < 2; i++ {
+				bs, res, err := fetchContent(context.Background(), srv.URL, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(bs) != rssFeed(1) || res.contentType != "application/rss+xml" {
+					t.Errorf("fetch %d: got %q, %s", i, bs, res.contentType)
+				}
+				time.Sleep(tt.sleep)
+			}
+			if got := n.Load(); got != tt.want {
+				t.Errorf("%d requests, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchCacheByURL(t *testing.T) {
+	setFetchCache(t, time.Minute)
+	var n atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		fmt.Fprint(w, r.URL.Path)
+	})
+	for _, path := range []string{"/a", "/b", "/a", "/b"} {
+		bs, err := fetch(context.Background(), srv.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != path {
+			t.Errorf("%s: got %s", path, bs)
+		}
+	}
+	if got := n.Load(); got != 2 {
+		t.Errorf("%d requests, want 2", got)
+	}
+}
+
+func TestFetchStreamCache(t *testing.T) {
+	setFetchCache(t, time.Minute)
+	small, large := rssFeed(2), rssFeed(10000)
+	if len(large) <= maxCachedBody {
+		t.Fatalf("the large feed is only %d bytes", len(large))
+	}
+	var n atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		if r.URL.Path == "/large" {
+			fmt.Fprint(w, large)
+			return
+		}
+		fmt.Fprint(w, small)
+	})
+	tests := []struct {
+		path string
+		want int32
+	}{
+		{"/small", 1},
+		{"/large", 2},
+	}
+	for _, tt := range tests {
+		n.Store(0)
+		for range 2 {
+			pod := newPod(Config{Name: "Test", URL: srv.URL + tt.path, Type: "rss"})
+			if _, _, err := pod.fetch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := n.Load(); got != tt.want {
+			t.Errorf("%s: %d requests, want %d", tt.path, got, tt.want)
+		}
+	}
+}
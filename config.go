@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a single podcast as read from the config file
+type Config struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+	Type string `json:"type" yaml:"type"`
+	// Parser is accepted as an alias for Type
+	Parser string `json:"parser,omitempty" yaml:"parser,omitempty"`
+	// MaxEpisodes overrides the -limit flag for this podcast, 0 means no limit
+	MaxEpisodes *int `json:"max_episodes,omitempty" yaml:"max_episodes,omitempty"`
+	// ClientID makes the soundcloud type read the tracks from the api, the
+	// spotify type requires it and ClientSecret
+	ClientID     string `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	// APIKey is the key for the youtube data api, required by that type
+	APIKey string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	// URLSelector, TitleSelector and BaseURL configure the scrape type, see
+	// ScrapedParser
+	URLSelector   string `json:"url_selector,omitempty" yaml:"url_selector,omitempty"`
+	TitleSelector string `json:"title_selector,omitempty" yaml:"title_selector,omitempty"`
+	BaseURL       string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+}
+
+// defaultConfig is used when no config file is given. The pods are stored
+// and shown under their lowercase name, so The Bike Shed keeps the bikeshed
+// name it had before there was a config.
+var defaultConfig = []Config{
+	{Name: "Filip & Fredrik", URL: "https://feed.pod.space/filipandfredrik", Type: "feed"},
+	{Name: "Alex & Sigge", URL: "http://alexosigge.libsyn.com/rss", Type: "feed"},
+	{Name: "Kodsnack", URL: "https://kodsnack.libsyn.com/rss", Type: "feed"},
+	{Name: "Go Time", URL: "https://changelog.com/gotime/feed", Type: "feed"},
+	{Name: "SE Radio", URL: "https://www.se-radio.net/feed/podcast/", Type: "feed"},
+	{Name: "bikeshed", URL: "https://rss.simplecast.com/podcasts/282/rss", Type: "feed"},
+	{Name: "On The Metal", URL: "https://feeds.transistor.fm/on-the-metal-0294649e-ec23-4eab-975a-9eb13fd94e06", Type: "feed"},
+	{Name: "Signals and Threads", URL: "https://feeds.simplecast.com/L9810DOa", Type: "feed"},
+}
+
+func (c Config) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if c.URL == "" {
+		return fmt.Errorf("%s: missing url", c.Name)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("%s: %s", c.Name, err.Error())
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s: url %q is not absolute", c.Name, c.URL)
+	}
+	if c.MaxEpisodes != nil && *c.MaxEpisodes < 0 {
+		return fmt.Errorf("%s: max_episodes can not be negative", c.Name)
+	}
+	if !knownType(c.Type) {
+		return fmt.Errorf("%s: unknown type %q", c.Name, c.Type)
+	}
+	switch c.Type {
+	case "soundcloud":
+		if !isSoundCloud(u) {
+			return fmt.Errorf("%s: url %q is not on soundcloud.com", c.Name, c.URL)
+		}
+	case "apple":
+		if _, ok := applePodcastID(u); !ok {
+			return fmt.Errorf("%s: url %q is not an apple podcasts url with an id", c.Name, c.URL)
+		}
+	case "scrape":
+		return c.validateScrape()
+	case "youtube":
+		if _, ok := youtubeChannelID(u); !ok {
+			return fmt.Errorf("%s: url %q is not a youtube channel url", c.Name, c.URL)
+		}
+		if c.APIKey == "" {
+			return fmt.Errorf("%s: missing api_key", c.Name)
+		}
+	case "acast":
+		if _, ok := acastSlug(u); !ok {
+			return fmt.Errorf("%s: url %q is not an acast show url", c.Name, c.URL)
+		}
+	case "spotify":
+		if _, ok := spotifyShowID(u); !ok {
+			return fmt.Errorf("%s: url %q is not a spotify show url", c.Name, c.URL)
+		}
+		if c.ClientID == "" || c.ClientSecret == "" {
+			return fmt.Errorf("%s: missing client_id or client_secret", c.Name)
+		}
+	}
+	return nil
+}
+
+// knownType reports whether t is a type of podcast that can be configured
+func knownType(t string) bool {
+	switch t {
+	case "rss", "atom", "rdf", "jsonfeed", "feed", "soundcloud", "apple", "scrape", "youtube", "spotify", "acast":
+		return true
+	}
+	return false
+}
+
+func (c Config) parser() parser {
+	switch c.Type {
+	case "rss":
+		return RssParser(c.URL)
+	case "atom":
+		return AtomParser(c.URL)
+	case "rdf":
+		return RdfParser(c.URL)
+	case "jsonfeed":
+		return JSONFeedParser(c.URL)
+	case "feed":
+		return &FeedParser{url: c.URL}
+	case "soundcloud":
+		return &SoundCloudParser{url: c.URL, ClientID: c.ClientID}
+	case "apple":
+		return &AppleParser{url: c.URL}
+	case "scrape":
+		return &ScrapedParser{url: c.URL,
+			URLSelector:   c.URLSelector,
+			TitleSelector: c.TitleSelector,
+			BaseURL:       c.BaseURL}
+	case "youtube":
+		return &YoutubeParser{url: c.URL, APIKey: c.APIKey}
+	case "spotify":
+		return &SpotifyParser{url: c.URL, ClientID: c.ClientID, ClientSecret: c.ClientSecret}
+	case "acast":
+		return AcastParser(c.URL)
+	}
+	return nil
+}
+
+// newPod creates a Pod from a validated config entry
+func newPod(c Config) *Pod {
+	n := *limit
+	if c.MaxEpisodes != nil {
+		n = *c.MaxEpisodes
+	}
+	return &Pod{
+		name:        c.Name,
+		url:         c.URL,
+		kind:        c.Type,
+		config:      c,
+		lastUpdate:  time.Now(),
+		parser:      c.parser(),
+		maxEpisodes: n,
+	}
+}
+
+// loadConfig reads and validates the list of podcasts in path, files ending
+// in .yml or .yaml are read as YAML and everything else as JSON
+func loadConfig(path string) ([]Config, error) {
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("config file %s does not exist", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfgs []Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(bs, &cfgs)
+	default:
+		err = json.Unmarshal(bs, &cfgs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("%s: no podcasts configured", path)
+	}
+
+	names := make(map[string]bool)
+	for i := range cfgs {
+		if cfgs[i].Type == "" {
+			cfgs[i].Type = cfgs[i].Parser
+		}
+		err = cfgs[i].validate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %s", path, i+1, err.Error())
+		}
+		key := strings.ToLower(cfgs[i].Name)
+		if names[key] {
+			return nil, fmt.Errorf("%s: entry %d: duplicate name %q", path, i+1, cfgs[i].Name)
+		}
+		names[key] = true
+	}
+	return cfgs, nil
+}
+
+// addPods registers a Pod for every entry in cfgs
+func addPods(cfgs []Config) {
+	for _, c := range cfgs {
+		store.Add(c.Name, newPod(c))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigJSONAndYAML(t *testing.T) {
+	fromJSON, err := loadConfig("testdata/pods.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := loadConfig("testdata/pods.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Fatalf("json and yaml configs differ:\n%+v\n%+v", fromJSON, fromYAML)
+	}
+
+	pods := func(cfgs []Config) map[string]string {
+		resetStore(t)
+		addPods(cfgs)
+		m := make(map[string]string)
+		for key, pod := range store.All() {
+			m[key] = fmt.Sprintf("%s %#v %d", pod.name, pod.parser, pod.maxEpisodes)
+		}
+		return m
+	}
+	jsonPods, yamlPods := pods(fromJSON), pods(fromYAML)
+	if len(jsonPods) != 3 {
+		t.Fatalf("got %d pods, want 3", len(jsonPods))
+	}
+	if !reflect.DeepEqual(jsonPods, yamlPods) {
+		t.Fatalf("json and yaml pods differ:\n%+v\n%+v", jsonPods, yamlPods)
+	}
+	if got, want := jsonPods["go time"], `Go Time "https://changelog.com/gotime/feed" 20`; got != want {
+		t.Errorf("go time: got %s, want %s", got, want)
+	}
+	if got, want := jsonPods["kodsnack"], fmt.Sprintf(`Kodsnack "https://kodsnack.libsyn.com/rss" %d`, *limit); got != want {
+		t.Errorf("kodsnack: got %s, want the -limit default in %s", got, want)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name, file, content, want string
+	}{
+		{"malformed yaml", "pods.yaml", "- name: Kodsnack\n  url: https://kodsnack.libsyn.com/rss\n  type: rss\n\tmax_episodes: 5\n", "line 3"},
+		{"duplicate name", "pods.json", `[{"name": "Kodsnack", "url": "https://a.example/rss", "type": "rss"},
+			{"name": "kodsnack", "url": "https://b.example/rss", "type": "rss"}]`, "duplicate name"},
+		{"relative url", "pods.yml", "- name: Kodsnack\n  url: /rss\n  type: rss\n", "not absolute"},
+		{"unknown type", "pods.json", `[{"name": "Kodsnack", "url": "https://a.example/rss", "type": "mp3"}]`, "unknown type"},
+		{"empty", "pods.json", `[]`, "no podcasts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadConfig(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	_, err := loadConfig(filepath.Join(t.TempDir(), "pods.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("got error %v, want a missing file error", err)
+	}
+}
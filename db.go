@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store keeps the pods and their episodes between restarts, one record per
+// pod under its lowercase name
+type Store interface {
+	// Save replaces the saved pod called name with ps
+	Save(name string, ps podState) error
+	// Delete removes the saved pod called name, a missing pod is not an error
+	Delete(name string) error
+	// Load returns every saved pod in order of their names, none when
+	// nothing has been saved
+	Load() ([]podState, error)
+}
+
+// stateStore is where the pods are saved, a FileStore with -state and a
+// BoltStore with -db. Without either nothing is saved.
+var stateStore Store = nopStore{}
+
+// nopStore saves nothing
+type nopStore struct{}
+
+func (nopStore) Save(string, podState) error { return nil }
+func (nopStore) Delete(string) error         { return nil }
+func (nopStore) Load() ([]podState, error)   { return nil, nil }
+
+// MemStore keeps the pods in memory
+type MemStore struct {
+	sync.Mutex
+	pods map[string]podState
+}
+
+// NewMemStore returns an empty MemStore
+func NewMemStore() *MemStore {
+	return &MemStore{pods: make(map[string]podState)}
+}
+
+// Save stores ps under name
+func (m *MemStore) Save(name string, ps podState) error {
+	m.Lock()
+	m.pods[strings.ToLower(name)] = ps
+	m.Unlock()
+	return nil
+}
+
+// Delete removes the pod called name
+func (m *MemStore) Delete(name string) error {
+	m.Lock()
+	delete(m.pods, strings.ToLower(name))
+	m.Unlock()
+	return nil
+}
+
+// Load returns a copy of the saved pods
+func (m *MemStore) Load() ([]podState, error) {
+	m.Lock()
+	defer m.Unlock()
+	var pods []podState
+	for _, ps := range m.pods {
+		pods = append(pods, ps)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return strings.ToLower(pods[i].Name) < strings.ToLower(pods[j].Name)
+	})
+	return pods, nil
+}
+
+// podsBucket holds every pod as json keyed by lowercase name
+var podsBucket = []byte("pods")
+
+// BoltStore keeps the pods in a bbolt database file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the database at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(podsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close closes the database
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// Save writes the record of the pod called name, the other pods are left
+// as they are
+func (b *BoltStore) Save(name string, ps podState) error {
+	bs, err := json.Marshal(ps)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(podsBucket).Put([]byte(strings.ToLower(name)), bs)
+	})
+}
+
+// Delete removes the record of the pod called name
+func (b *BoltStore) Delete(name string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(podsBucket).Delete([]byte(strings.ToLower(name)))
+	})
+}
+
+// Load returns the saved pods in order of their keys
+func (b *BoltStore) Load() ([]podState, error) {
+	var pods []podState
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(podsBucket).ForEach(func(k, v []byte) error {
+			var ps podState
+			err := json.Unmarshal(v, &ps)
+			pods = append(pods, ps)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods, nil
+}
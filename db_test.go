@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testStores returns an empty store of every kind
+func testStores(t *testing.T) map[string]Store {
+	dir := t.TempDir()
+	bs, err := NewBoltStore(filepath.Join(dir, "pods.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bs.Close() })
+	return map[string]Store{
+		"mem":  NewMemStore(),
+		"file": NewFileStore(filepath.Join(dir, "state.json")),
+		"bolt": bs,
+	}
+}
+
+func TestStores(t *testing.T) {
+	a := podState{Name: "Alex & Sigge", URL: "https://example.com/a", Type: "rss", MaxEpisodes: 10,
+		Added:      true,
+		LastUpdate: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Metadata:   PodMetadata{Description: "Om allt"},
+		Episodes: []episodeState{{Title: "Avsnitt 1", URL: "https://example.com/1.mp3",
+			PubDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), GUID: "ep-1", Duration: 60}}}
+	b := podState{Name: "Kodsnack", URL: "https://example.com/b", Type: "feed", Episodes: []episodeState{}}
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			got, err := s.Load()
+			if err != nil || len(got) != 0 {
+				t.Fatalf("empty store: got %v, %v", got, err)
+			}
+
+			for _, ps := range []podState{b, a} {
+				if err := s.Save(ps.Name, ps); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err = s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []podState{a, b}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+
+			// saving a pod leaves the others as they are
+			a2 := a
+			a2.URL = "https://example.com/moved"
+			if err := s.Save("alex & sigge", a2); err != nil {
+				t.Fatal(err)
+			}
+			got, err = s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []podState{a2, b}; !reflect.DeepEqual(got, want) {
+				t.Errorf("after saving a again got %+v, want %+v", got, want)
+			}
+
+			for _, name := range []string{"Kodsnack", "Kodsnack"} {
+				if err := s.Delete(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err = s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []podState{a2}; !reflect.DeepEqual(got, want) {
+				t.Errorf("after removing b got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestNopStore(t *testing.T) {
+	var s Store = nopStore{}
+	if err := s.Save("a", podState{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Load(); err != nil || got != nil {
+		t.Errorf("got %v, %v after a save, want nothing", got, err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// findEpisode returns the episode of the pod called name whose title, guid
+// or url is ep
+func findEpisode(name, ep string) (Episode, bool) {
+	store.RLock()
+	defer store.RUnlock()
+	pod, ok := store.pods[strings.ToLower(name)]
+	if !ok {
+		return Episode{}, false
+	}
+	for _, e := range pod.eps {
+		if strings.EqualFold(e.name, ep) || e.key() == ep {
+			return e, true
+		}
+	}
+	return Episode{}, false
+}
+
+// cacheName is the file name an episode is downloaded to, the sanitized
+// title and a hash of the url so episodes with the same title don't clash
+func cacheName(ep Episode) string {
+	title := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, ep.name)
+	if r := []rune(title); len(r) > 64 {
+		title = string(r[:64])
+	}
+	sum := sha256.Sum256([]byte(ep.url))
+	ext := ".mp3"
+	if u, err := url.Parse(ep.url); err == nil && path.Ext(u.Path) != "" {
+		ext = path.Ext(u.Path)
+	}
+	return title + "-" + hex.EncodeToString(sum[:6]) + ext
+}
+
+// downloadEpisode saves the audio of ep to file, through a temp file in the
+// same directory so an interrupted download never looks complete
+func downloadEpisode(r *http.Request, ep Episode, file string) error {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, ep.url, nil)
+	if err != nil {
+		return err
+	}
+	// the fetch timeout is meant for feeds, audio can take a lot longer
+	res, err := (&http.Client{Transport: client.Transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &statusError{url: ep.url, code: res.StatusCode, status: res.Status}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, res.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// download serves GET /download?pod=X&ep=Y, the audio of an episode from
+// the -cache directory, downloading it there first if needed
+func download(w http.ResponseWriter, r *http.Request) {
+	if *cacheDir == "" {
+		http.Error(w, "downloads are disabled, start with -cache", http.StatusNotFound)
+		return
+	}
+	ep, ok := findEpisode(r.URL.Query().Get("pod"), r.URL.Query().Get("ep"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	file := filepath.Join(*cacheDir, cacheName(ep))
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		slog.Info("downloading episode", "podcast", r.URL.Query().Get("pod"), "url", ep.url)
+		err = downloadEpisode(r, ep, file)
+		if err != nil {
+			slog.Error("download failed", "url", ep.url, "err", err)
+			http.Error(w, fmt.Sprintf("downloading episode: %s", err.Error()), http.StatusBadGateway)
+			return
+		}
+	}
+	http.ServeFile(w, r, file)
+}
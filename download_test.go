@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// setCacheDir sets -cache for the test
+func setCacheDir(t *testing.T, dir string) {
+	t.Helper()
+	old := *cacheDir
+	*cacheDir = dir
+	t.Cleanup(func() { *cacheDir = old })
+}
+
+func TestDownload(t *testing.T) {
+	resetStore(t)
+	var hits atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/broken.mp3" {
+			http.Error(w, "gone", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("audio"))
+	})
+	pod := newPod(Config{Name: "Kodsnack", URL: srv.URL + "/feed", Type: "rss"})
+	pod.eps = []Episode{{name: "Avsnitt 1", url: srv.URL + "/1.mp3"},
+		{name: "Avsnitt 2", url: srv.URL + "/broken.mp3"}}
+	store.Add("Kodsnack", pod)
+
+	get := func(ep string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		download(w, httptest.NewRequest("GET", "/download?pod=kodsnack&ep="+url.QueryEscape(ep), nil))
+		return w
+	}
+
+	setCacheDir(t, "")
+	if w := get("Avsnitt 1"); w.Code != http.StatusNotFound {
+		t.Errorf("without -cache: got %d, want 404", w.Code)
+	}
+
+	dir := t.TempDir()
+	setCacheDir(t, dir)
+	if w := get("Avsnitt 3"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown episode: got %d, want 404", w.Code)
+	}
+	for i := 0; i < 2; i++ {
+		w := get("avsnitt 1")
+		if w.Code != http.StatusOK || w.Body.String() != "audio" {
+			t.Errorf("download %d: got %d %q", i, w.Code, w.Body)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("fetched the episode %d times, want once", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, cacheName(pod.eps[0]))); err != nil {
+		t.Errorf("episode not cached: %s", err)
+	}
+
+	if w := get("Avsnitt 2"); w.Code != http.StatusBadGateway {
+		t.Errorf("upstream error: got %d, want 502", w.Code)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("got %d files in the cache, want only the first episode", len(files))
+	}
+}
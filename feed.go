@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// FeedParser implements the parser interface for feeds of unknown format.
+// The format is detected on the first fetch and remembered for later ones.
+type FeedParser struct {
+	url string
+	// format is "rss", "atom", "rdf" or "jsonfeed" once detected
+	format string
+}
+
+// URLs fetches the feed and extracts at most limit media-links from it as
+// rss, atom or rdf depending on the root element, or as a json feed. The
+// root element is looked for in the first sniffSize bytes, an rss feed is
+// then decoded as it is read like with the rss type.
+func (fp *FeedParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	body, res, err := fetchStream(ctx, fp.url, v.header(fp.url))
+	if err != nil {
+		return res, err
+	}
+	defer body.Close()
+	br := bufio.NewReaderSize(body, sniffSize)
+	// a feed shorter than sniffSize is all there is, and a read error comes
+	// back when the rest is read
+	prefix, _ := br.Peek(sniffSize)
+	if sniffFormat(prefix) == "rss" {
+		res.eps, res.meta, err = parseRss(br, limit)
+		if err == nil || isPartial(err) {
+			fp.format = "rss"
+		}
+		return res, err
+	}
+	bs, err := io.ReadAll(br)
+	if err != nil {
+		return res, err
+	}
+	if fp.format != "" {
+		res.eps, res.meta, err = parseFormat(fp.format, bs, limit)
+		if err == nil || isPartial(err) {
+			return res, err
+		}
+		// the feed may have changed format, detect it again
+		slog.Debug("feed changed format", "url", fp.url, "format", fp.format, "err", err)
+	}
+	format, err := detectFormat(bs, res.contentType)
+	if err != nil {
+		return res, fmt.Errorf("%s (content-type %q, starts with %q)", err.Error(), res.contentType, head(bs, 100))
+	}
+	res.eps, res.meta, err = parseFormat(format, bs, limit)
+	if err == nil || isPartial(err) {
+		fp.format = format
+	}
+	return res, err
+}
+
+// sniffSize is how much of a feed NewPodFromURL and FeedParser read to find
+// its format before decoding it
+const sniffSize = 512
+
+// NewPodFromURL fetches the feed at url and returns a Pod with the parser for
+// its format, found from the root element in the first sniffSize bytes so
+// pasting a url is enough to subscribe. The pod is named after the title of
+// the feed and already has its first episodes.
+func NewPodFromURL(url string) (*Pod, error) {
+	body, res, err := fetchStream(context.Background(), url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	br := bufio.NewReaderSize(body, sniffSize)
+	prefix := make([]byte, sniffSize)
+	n, err := io.ReadFull(br, prefix)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	prefix = prefix[:n]
+	format := sniffFormat(prefix)
+
+	// the sniffed bytes go back in front of the rest for parsing
+	bs, err := io.ReadAll(io.MultiReader(bytes.NewReader(prefix), br))
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		// json feeds and xml with a long prolog need the whole document
+		format, err = detectFormat(bs, res.contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%s (content-type %q, starts with %q)", err.Error(), res.contentType, head(bs, 100))
+		}
+	}
+
+	pod := newPod(Config{Name: url, URL: url, Type: format})
+	eps, meta, err := parseFormat(format, bs, pod.maxEpisodes)
+	if err != nil && !isPartial(err) {
+		return nil, err
+	}
+	if len(eps) == 0 {
+		return nil, errNoEpisodes
+	}
+	if meta.Title != "" {
+		pod.name = meta.Title
+		pod.config.Name = meta.Title
+	}
+	pod.moved = res.movedTo
+	resolveURLs(firstNonEmpty(res.movedTo, url), eps)
+	sort.Stable(byEpisodeDate(eps))
+	pod.record(eps, meta, err, time.Now())
+	return pod, nil
+}
+
+// sniffFormat returns the format of the xml feed starting with prefix from
+// its root element, or "" when the root element is not in prefix
+func sniffFormat(prefix []byte) string {
+	root, err := rootElement(prefix)
+	if err != nil {
+		return ""
+	}
+	switch root.Local {
+	case "rss":
+		return "rss"
+	case "feed":
+		return "atom"
+	case "RDF":
+		return "rdf"
+	}
+	return ""
+}
+
+// parseFeed detects the format of the document in bs and parses it
+func parseFeed(bs []byte, contentType string, limit int) ([]Episode, PodMetadata, error) {
+	format, err := detectFormat(bs, contentType)
+	if err != nil {
+		return nil, PodMetadata{}, err
+	}
+	return parseFormat(format, bs, limit)
+}
+
+// parseFormat parses bs as the given format
+func parseFormat(format string, bs []byte, limit int) ([]Episode, PodMetadata, error) {
+	switch format {
+	case "rss":
+		return parseRss(bytes.NewReader(bs), limit)
+	case "atom":
+		return parseAtom(bs, limit)
+	case "rdf":
+		return parseRdf(bs, limit)
+	case "jsonfeed":
+		return parseJSONFeed(bs, limit)
+	}
+	return nil, PodMetadata{}, fmt.Errorf("unknown feed format %q", format)
+}
+
+// detectFormat returns the feed format of bs from its root element, or
+// "jsonfeed" for a JSON Feed
+func detectFormat(bs []byte, contentType string) (string, error) {
+	if isJSONFeed(bs, contentType) {
+		return "jsonfeed", nil
+	}
+	root, err := rootElement(bs)
+	if err != nil {
+		return "", err
+	}
+	switch root.Local {
+	case "rss":
+		return "rss", nil
+	case "feed":
+		return "atom", nil
+	case "RDF":
+		return "rdf", nil
+	}
+	return "", fmt.Errorf("not an rss, atom, rdf or json feed: root element is <%s>", root.Local)
+}
+
+// rootElement returns the name of the first element in bs
+func rootElement(bs []byte) (xml.Name, error) {
+	d := newDecoder(bytes.NewReader(bs))
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return xml.Name{}, fmt.Errorf("no root element")
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if se, ok := t.(xml.StartElement); ok {
+			return se.Name, nil
+		}
+	}
+}
+
+// head returns at most the first n bytes of bs
+func head(bs []byte, n int) string {
+	if len(bs) > n {
+		bs = bs[:n]
+	}
+	return string(bs)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFeedFormats(t *testing.T) {
+	kodsnack := []string{"Kodsnack 2 - Andra avsnittet", "Kodsnack 1 - Första avsnittet"}
+	tests := []struct {
+		file   string
+		format string
+		want   []string
+	}{
+		{"dated.rss", "rss", []string{"Fourth", "Third", "Second", "First"}},
+		{"feed.atom", "atom", kodsnack},
+		{"feed.rdf", "rdf", kodsnack},
+		{"podcast.json", "jsonfeed", []string{"Video first", "Audio only"}},
+	}
+	for _, tt := range tests {
+		srv := serveFile(t, tt.file)
+		pod := newPod(Config{Name: "Test", URL: srv.URL + "/" + tt.file, Type: "feed"})
+		eps, meta, err := pod.fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if got := titles(eps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.file, got, tt.want)
+		}
+		if got := pod.parser.(*FeedParser).format; got != tt.format {
+			t.Errorf("%s: detected %q, want %q", tt.file, got, tt.format)
+		}
+		if tt.format == "atom" || tt.format == "rdf" {
+			want := PodMetadata{Title: "Kodsnack",
+				Description: "Ett poddradioprogram om utveckling",
+				Link:        "https://kodsnack.se/",
+				Image:       "https://kodsnack.se/logo.png"}
+			if meta != want {
+				t.Errorf("%s: metadata %+v, want %+v", tt.file, meta, want)
+			}
+			if ep := eps[0]; ep.url != "https://media.kodsnack.se/2.mp3" || ep.length != 2048 || ep.description != "Vi pratar om testning" {
+				t.Errorf("%s: first episode %s, %d bytes, %q", tt.file, ep.url, ep.length, ep.description)
+			}
+		}
+	}
+}
+
+func TestFeedLongProlog(t *testing.T) {
+	// the root element comes after sniffSize bytes, so the format is found
+	// from the whole feed instead
+	prolog := "<?xml version=\"1.0\"?>\n<!-- " + strings.Repeat("x", sniffSize) + " -->\n"
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Replace(rssFeed(3), `<?xml version="1.0" encoding="UTF-8"?>`, prolog, 1))
+	})
+	pod := newPod(Config{Name: "Test", URL: srv.URL, Type: "feed"})
+	eps, _, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 3 {
+		t.Errorf("got %d episodes, want 3", len(eps))
+	}
+	if got := pod.parser.(*FeedParser).format; got != "rss" {
+		t.Errorf("detected %q, want rss", got)
+	}
+}
+
+func TestFeedChangesFormat(t *testing.T) {
+	var requests atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		name := "testdata/feed.rdf"
+		if requests.Add(1) == 1 {
+			name = "testdata/feed.atom"
+		}
+		bs, err := os.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write(bs)
+	})
+	pod := newPod(Config{Name: "Test", URL: srv.URL, Type: "feed"})
+	for _, want := range []string{"atom", "rdf"} {
+		eps, _, err := pod.fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		if len(eps) != 2 {
+			t.Errorf("%s: got %d episodes, want 2", want, len(eps))
+		}
+		if got := pod.parser.(*FeedParser).format; got != want {
+			t.Errorf("detected %q, want %q", got, want)
+		}
+	}
+}
+
+func TestNewPodFromURL(t *testing.T) {
+	tests := []struct {
+		file, kind, name string
+		first            string
+	}{
+		{"dated.rss", "rss", "Oldest first", "Fourth"},
+		{"feed.atom", "atom", "Kodsnack", "Kodsnack 2 - Andra avsnittet"},
+		{"feed.rdf", "rdf", "Kodsnack", "Kodsnack 2 - Andra avsnittet"},
+		{"podcast.json", "jsonfeed", "Json-podden", "Video first"},
+	}
+	for _, tt := range tests {
+		srv := serveFile(t, tt.file)
+		pod, err := NewPodFromURL(srv.URL + "/" + tt.file)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if pod.kind != tt.kind || pod.name != tt.name {
+			t.Errorf("%s: got a %s pod named %q, want a %s pod named %q", tt.file, pod.kind, pod.name, tt.kind, tt.name)
+		}
+		if len(pod.eps) == 0 || pod.eps[0].name != tt.first {
+			t.Errorf("%s: got episodes %q, want %q first", tt.file, titles(pod.eps), tt.first)
+		}
+	}
+
+	// the root element comes after the sniffed bytes
+	prolog := "<?xml version=\"1.0\"?>\n<!-- " + strings.Repeat("x", sniffSize) + " -->\n"
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Replace(rssFeed(3), `<?xml version="1.0" encoding="UTF-8"?>`, prolog, 1))
+	})
+	pod, err := NewPodFromURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod.kind != "rss" || len(pod.eps) != 3 {
+		t.Errorf("long prolog: got a %s pod with %d episodes, want rss with 3", pod.kind, len(pod.eps))
+	}
+
+	srv = serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>not a feed</body></html>")
+	})
+	if _, err := NewPodFromURL(srv.URL); err == nil || !strings.Contains(err.Error(), "root element is <html>") {
+		t.Errorf("html page: got %v, want a root element error", err)
+	}
+}
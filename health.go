@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// staleAfter is how many update intervals a pod may go without a successful
+// update before it is reported as unhealthy
+const staleAfter = 3
+
+// PodHealth is the status of a single pod in /healthz
+type PodHealth struct {
+	Name       string `json:"name"`
+	LastUpdate string `json:"last_update"`
+	Episodes   int    `json:"episodes"`
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error,omitempty"`
+}
+
+// failing reports whether the last update of pod failed. Like in record, a
+// partial error or being rate limited is not a failure. The caller must hold
+// the store lock.
+func (p *Pod) failing() bool {
+	return p.lastError != nil && !isPartial(p.lastError) && p.rateLimited.IsZero()
+}
+
+// healthy reports whether pod updated recently and without failing, the
+// caller must hold the store lock
+func (p *Pod) healthy(now time.Time) bool {
+	return !p.failing() && now.Sub(p.lastUpdate) <= staleAfter**interval
+}
+
+// healthz serves the fetch status of every pod and answers 503 if any of
+// them is unhealthy
+func healthz(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	data := []PodHealth{}
+	status := http.StatusOK
+
+	store.RLock()
+	for name, pod := range store.pods {
+		ph := PodHealth{Name: name,
+			LastUpdate: pod.lastUpdate.Format(time.RFC3339),
+			Episodes:   len(pod.eps),
+			Healthy:    pod.healthy(now)}
+		if pod.lastError != nil {
+			ph.Error = pod.lastError.Error()
+		}
+		if !ph.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		data = append(data, ph)
+	}
+	store.RUnlock()
+
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Name < data[j].Name
+	})
+	writeJSON(w, status, data)
+}
+
+// started is when the service started, for the uptime in /health
+var started = time.Now()
+
+// Health is the body of /health
+type Health struct {
+	Status        string            `json:"status"`
+	UptimeSeconds int64             `json:"uptime_seconds,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+}
+
+// health serves the overall status of the service, it is degraded when one
+// or more pods failed their last update
+func health(w http.ResponseWriter, r *http.Request) {
+	details := make(map[string]string)
+	store.RLock()
+	for name, pod := range store.pods {
+		if pod.failing() {
+			details[name] = pod.lastError.Error()
+		}
+	}
+	store.RUnlock()
+
+	if len(details) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, Health{Status: "degraded", Details: details})
+		return
+	}
+	writeJSON(w, http.StatusOK, Health{Status: "ok", UptimeSeconds: int64(time.Since(started).Seconds())})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// addHealthPod adds a pod that last updated at the given time and recorded
+// err after it, if err is set
+func addHealthPod(name string, updated time.Time, err error) {
+	pod := newPod(Config{Name: name, URL: "https://example.com/" + name, Type: "rss"})
+	pod.record(nil, PodMetadata{}, nil, updated)
+	if err != nil {
+		pod.record(nil, PodMetadata{}, err, updated)
+	}
+	store.Add(name, pod)
+}
+
+// partial is an update that skipped some items
+var partial = &skippedError{skipped: 1, total: 2, err: errors.New("bad item")}
+
+// rateLimited is an update that was asked to come back in an hour
+var rateLimited = &statusError{url: "https://example.com/", code: http.StatusTooManyRequests,
+	status: "429 Too Many Requests", retryAfter: time.Now().Add(time.Hour)}
+
+func TestHealthz(t *testing.T) {
+	resetStore(t)
+	now := time.Now()
+	addHealthPod("ok", now, nil)
+	addHealthPod("failing", now, errors.New("boom"))
+	addHealthPod("stale", now.Add(-(staleAfter+1)**interval), nil)
+	addHealthPod("partial", now, partial)
+	addHealthPod("limited", now, rateLimited)
+
+	w := httptest.NewRecorder()
+	healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, want 503", w.Code)
+	}
+	var got []PodHealth
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %s", err, w.Body)
+	}
+	status := make(map[string]bool)
+	for _, ph := range got {
+		status[ph.Name] = ph.Healthy
+		if (ph.Error != "") != (ph.Name != "ok" && ph.Name != "stale") {
+			t.Errorf("%s: got error %q", ph.Name, ph.Error)
+		}
+	}
+	want := map[string]bool{"ok": true, "failing": false, "stale": false,
+		"partial": true, "limited": true}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("got %v, want %v", status, want)
+	}
+
+	resetStore(t)
+	addHealthPod("ok", now, nil)
+	w = httptest.NewRecorder()
+	healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("all healthy: got %d, want 200", w.Code)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		errs    map[string]error
+		code    int
+		status  string
+		details map[string]string
+	}{
+		{"ok", map[string]error{"a": nil, "b": nil}, http.StatusOK, "ok", nil},
+		{"degraded", map[string]error{"a": nil, "b": errors.New("boom")},
+			http.StatusServiceUnavailable, "degraded", map[string]string{"b": "boom"}},
+		{"partial or rate limited", map[string]error{"a": partial, "b": rateLimited}, http.StatusOK, "ok", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStore(t)
+			for name, err := range tt.errs {
+				addHealthPod(name, now, err)
+			}
+			w := httptest.NewRecorder()
+			health(w, httptest.NewRequest("GET", "/health", nil))
+			var got Health
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("%s: %s", err, w.Body)
+			}
+			if w.Code != tt.code || got.Status != tt.status || !reflect.DeepEqual(got.Details, tt.details) {
+				t.Errorf("got %d %+v, want %d %s %v", w.Code, got, tt.code, tt.status, tt.details)
+			}
+		})
+	}
+}
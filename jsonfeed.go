@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"strings"
+	"time"
+)
+
+// JSONFeed is a feed in the jsonfeed.org format, version 1 or 1.1
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	HomePageURL string         `json:"home_page_url"`
+	Icon        string         `json:"icon"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+// JSONFeedItem is an item of a JSONFeed
+type JSONFeedItem struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title"`
+	Summary       string               `json:"summary"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	DatePublished string               `json:"date_published"`
+	Image         string               `json:"image"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+// JSONFeedAttachment is a media file of an item
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+// jsonFeedVersion is the prefix of the version of every JSON Feed
+const jsonFeedVersion = "https://jsonfeed.org/version/1"
+
+// audio returns the first attachment with an audio mime type
+func (it JSONFeedItem) audio() (JSONFeedAttachment, bool) {
+	for _, a := range it.Attachments {
+		if strings.HasPrefix(a.MimeType, "audio/") {
+			return a, true
+		}
+	}
+	return JSONFeedAttachment{}, false
+}
+
+// description returns the html content, falling back to the text and summary
+func (it JSONFeedItem) description() string {
+	for _, s := range []string{it.ContentHTML, it.ContentText, it.Summary} {
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// JSONFeedParser implements the parser interface and the string is the url for the feed
+type JSONFeedParser string
+
+// URLs extracts at most limit audio links from the json feed, limit 0 means all of them
+func (jp JSONFeedParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	bs, res, err := fetchContent(ctx, string(jp), v.header(string(jp)))
+	if err != nil {
+		return res, err
+	}
+	res.eps, res.meta, err = parseJSONFeed(bs, limit)
+	return res, err
+}
+
+// parseJSONFeed extracts at most limit episodes and the feed metadata from
+// the json feed in bs
+func parseJSONFeed(bs []byte, limit int) ([]Episode, PodMetadata, error) {
+	jf := JSONFeed{}
+	err := json.Unmarshal(bs, &jf)
+	if err != nil {
+		return nil, PodMetadata{}, err
+	}
+
+	eps := make([]Episode, 0, len(jf.Items))
+	for _, it := range jf.Items {
+		a, ok := it.audio()
+		if !ok {
+			continue
+		}
+		// an unparsable date is left as the zero time like in rss
+		published, _ := time.Parse(time.RFC3339, it.DatePublished)
+		eps = append(eps, Episode{name: it.Title,
+			subtitle:    it.Summary,
+			url:         a.URL,
+			pubDate:     published,
+			description: it.description(),
+			duration:    int64(a.DurationInSeconds),
+			length:      a.SizeInBytes,
+			guid:        it.ID,
+			image:       it.Image,
+			mediaType:   a.MimeType})
+	}
+	meta := PodMetadata{Title: strings.TrimSpace(jf.Title),
+		Description: strings.TrimSpace(jf.Description),
+		Link:        strings.TrimSpace(jf.HomePageURL),
+		Image:       strings.TrimSpace(jf.Icon)}
+	return newest(eps, limit), meta, nil
+}
+
+// isJSONFeed reports whether bs is a JSON Feed, based on the content type
+// and the version in the document
+func isJSONFeed(bs []byte, contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt != "application/feed+json" && mt != "application/json" &&
+		!bytes.HasPrefix(bytes.TrimSpace(bs), []byte("{")) {
+		return false
+	}
+	var v struct {
+		Version string `json:"version"`
+	}
+	return json.Unmarshal(bs, &v) == nil && strings.HasPrefix(v.Version, jsonFeedVersion)
+}
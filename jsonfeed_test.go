@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONFeedAttachments(t *testing.T) {
+	for _, kind := range []string{"jsonfeed", "feed"} {
+		eps := fetchFixture(t, kind, "podcast.json")
+		if got, want := titles(eps), []string{"Video first", "Audio only"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got %q, want %q", kind, got, want)
+		}
+		ep := eps[0]
+		if ep.url != "https://example.com/3.m4a" || ep.mediaType != "audio/x-m4a" || ep.length != 1234 || ep.duration != 61 {
+			t.Errorf("%s: picked %s %s, %d bytes, %ds", kind, ep.url, ep.mediaType, ep.length, ep.duration)
+		}
+		if ep.description != "<p>Anteckningar</p>" || ep.subtitle != "Först en video" {
+			t.Errorf("%s: description %q, subtitle %q", kind, ep.description, ep.subtitle)
+		}
+		if ep := eps[1]; !ep.pubDate.IsZero() || ep.description != "Bara ljud" {
+			t.Errorf("%s: undated episode has date %s, description %q", kind, ep.pubDate, ep.description)
+		}
+	}
+}
+
+func TestIsJSONFeed(t *testing.T) {
+	tests := []struct {
+		body, contentType string
+		want              bool
+	}{
+		{`{"version": "https://jsonfeed.org/version/1"}`, "", true},
+		{`{"version": "https://jsonfeed.org/version/1.1"}`, "application/feed+json", true},
+		{`{"version": "2"}`, "application/json", false},
+		{`<rss version="2.0"/>`, "application/json", false},
+		{`[]`, "", false},
+	}
+	for _, tt := range tests {
+		if got := isJSONFeed([]byte(tt.body), tt.contentType); got != tt.want {
+			t.Errorf("isJSONFeed(%s, %q) = %v, want %v", tt.body, tt.contentType, got, tt.want)
+		}
+	}
+}
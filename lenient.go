@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+)
+
+// xmlEncoding finds the encoding in the xml declaration of a document
+var xmlEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*encoding\s*=\s*["']([^"']+)["']`)
+
+// utf8BOM is stripped from the start of documents, encoding/xml fails on it
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// prologSize is how much of a document is searched for its encoding
+const prologSize = 200
+
+// utf8Reader returns r converted from the encoding in its xml declaration,
+// such as ISO-8859-1 or windows-1252, without a UTF-8 BOM and without
+// characters that are invalid in xml
+func utf8Reader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(len(utf8BOM)); bytes.Equal(bom, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	var in io.Reader = br
+	// a short document is all there is, so the error is of no interest
+	prolog, _ := br.Peek(prologSize)
+	if m := xmlEncoding.FindSubmatch(prolog); m != nil && !strings.EqualFold(string(m[1]), "utf-8") {
+		if enc, _ := charset.Lookup(string(m[1])); enc != nil {
+			in = enc.NewDecoder().Reader(br)
+		}
+	}
+	return transform.NewReader(in, runes.Remove(runes.Predicate(invalidXMLChar)))
+}
+
+// invalidXMLChar reports the characters encoding/xml refuses, such as stray
+// control characters, they are dropped instead of failing the whole feed
+func invalidXMLChar(r rune) bool {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return false
+	case r < 0x20, r >= 0xd800 && r <= 0xdfff, r == 0xfffe, r == 0xffff:
+		return true
+	}
+	return false
+}
+
+// newDecoder returns the lenient decoder every xml document is read with,
+// feeds of all formats as well as opml. The document is converted to UTF-8
+// from whatever encoding it declares, HTML entities such as &nbsp; are
+// understood and unknown ones are kept as text.
+func newDecoder(r io.Reader) *xml.Decoder {
+	return utf8Decoder(utf8Reader(r))
+}
+
+// utf8Decoder is newDecoder for a document that has already been through
+// utf8Reader
+func utf8Decoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	// the document is UTF-8 already, whatever the declaration says
+	d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		return r, nil
+	}
+	return d
+}
+
+// skippedError is returned with the episodes of a feed where some items
+// could not be parsed
+type skippedError struct {
+	skipped int
+	total   int
+	err     error
+}
+
+func (e *skippedError) Error() string {
+	return fmt.Sprintf("skipped %d of %d items: %s", e.skipped, e.total, e.err.Error())
+}
+
+func (e *skippedError) Unwrap() error { return e.err }
+
+// isPartial reports whether err only means that some items were skipped
+func isPartial(err error) bool {
+	var se *skippedError
+	return errors.As(err, &se)
+}
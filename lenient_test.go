@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeedEncodings(t *testing.T) {
+	tests := []struct {
+		file, title string
+	}{
+		{"latin1.rss", "År är över"},
+		{"windows-1252.rss", "“Vår” kostar 5 €"},
+		{"bom.rss", "Åsa älskar öl"},
+	}
+	for _, tt := range tests {
+		for _, kind := range []string{"rss", "feed"} {
+			eps := fetchFixture(t, kind, tt.file)
+			if len(eps) != 1 {
+				t.Errorf("%s as %s: got %d episodes, want 1", tt.file, kind, len(eps))
+				continue
+			}
+			if eps[0].name != tt.title {
+				t.Errorf("%s as %s: title %q, want %q", tt.file, kind, eps[0].name, tt.title)
+			}
+			if want := "Smörgåsbord och räksmörgås"; eps[0].description != want {
+				t.Errorf("%s as %s: description %q, want %q", tt.file, kind, eps[0].description, want)
+			}
+		}
+	}
+}
+
+func TestPoisonedItem(t *testing.T) {
+	resetStore(t)
+	srv := serveFile(t, "poisoned.rss")
+	store.Add("Poisoned", newPod(Config{Name: "Poisoned", URL: srv.URL + "/poisoned.rss", Type: "rss"}))
+	store.Update(context.Background(), true)
+
+	pod, _ := store.Get("Poisoned")
+	store.RLock()
+	got, err := titles(pod.eps), pod.lastError
+	store.RUnlock()
+	want := []string{"Avsnitt 4 — entities &unknown;", "Avsnitt 3 with a stray control character", "Avsnitt 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var se *skippedError
+	if !errors.As(err, &se) || se.skipped != 1 || se.total != 4 {
+		t.Fatalf("got error %v, want 1 of 4 items skipped", err)
+	}
+	if pod.failures != 0 {
+		t.Errorf("a skipped item counted as a failure")
+	}
+
+	w := httptest.NewRecorder()
+	index(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "with problems: skipped 1 of 4 items") {
+		t.Error("the skipped item is not shown as a warning")
+	}
+	if strings.Contains(body, "Update failed") {
+		t.Error("a partial update is shown as failed")
+	}
+}
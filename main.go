@@ -1,41 +1,177 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var port = flag.String("port", ":6363", "port to listen to :XXXX")
+var addr = flag.String("addr", ":6363", "address to listen to host:port")
+var port = flag.String("port", "", "deprecated: use -addr")
+var config = flag.String("config", "", "path to a JSON or YAML file listing the podcasts")
+var limit = flag.Int("limit", 10, "max number of episodes per podcast, 0 means no limit")
+var timeout = flag.Duration("timeout", 30*time.Second, "timeout for fetching a feed")
+var interval = flag.Duration("interval", time.Hour, "time between updates, at least 1m")
+var workers = flag.Int("workers", 4, "number of feeds to fetch at the same time")
+var state = flag.String("state", "", "path to a JSON file that keeps the podcasts and episodes between restarts")
+var dbPath = flag.String("db", "", "path to a bbolt database to keep the podcasts and episodes in instead of -state")
+var token = flag.String("token", "", "bearer token required by requests that change the podcasts")
+var retries = flag.Int("retries", 3, "max attempts for a fetch that fails with a server or network error")
+var maxIdle = flag.Int("max-idle", 10, "max idle connections kept open per feed host")
+var cacheTTL = flag.Duration("cache-ttl", 5*time.Minute, "how long fetched feeds are reused, 0 disables the cache")
+var cacheDir = flag.String("cache", "", "directory to keep downloaded episodes in, /download is off without it")
+var checkFeeds = flag.Bool("check-feeds", false, "fetch every configured feed at startup and leave out the ones that fail")
+var maxFeedSize = flag.Int64("max-feed-size", 10<<20, "max size in bytes of a fetched feed")
+var maxFailures = flag.Int("max-failures", 5, "failures in a row before a podcast is disabled, 0 never disables")
+var logLevel = flag.String("log-level", "info", "minimum level logged: debug, info, warn or error")
+var logFormat = flag.String("log-format", "text", "format of the log: text or json")
+var verbose = flag.Bool("verbose", false, "deprecated: use -log-level debug")
+var grace = flag.Duration("grace", 10*time.Second, "time to wait for requests and updates on shutdown")
+
+func init() {
+	flag.IntVar(limit, "max-episodes", *limit, "alias for -limit")
+	flag.DurationVar(timeout, "fetch-timeout", *timeout, "alias for -timeout")
+}
+
+// client is used for all feed fetches, its timeout and transport are set
+// from the flags in main
+var client = &http.Client{CheckRedirect: checkRedirect}
+
+// newTransport returns a transport that keeps up to maxIdle connections open
+// per host, so feeds on the same host reuse them between fetches
+func newTransport(maxIdle int) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 0
+	t.MaxIdleConnsPerHost = maxIdle
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
 
 // RssFeed is the root of the feed
 type RssFeed struct {
 	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr,omitempty"`
 	Channel RssChannel `xml:"channel"`
 }
 
 // RssChannel is a channel
 type RssChannel struct {
-	Title string    `xml:"title"`
-	Items []RssItem `xml:"item"`
+	Title       string `xml:"title"`
+	Description string `xml:"description,omitempty"`
+	// Links is a slice since atom:link elements match the tag as well
+	Links []string `xml:"link,omitempty"`
+	// ItunesImage must come before Image, which matches itunes:image too
+	ItunesImage *ItunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image,omitempty"`
+	Image       *RssImage    `xml:"image,omitempty"`
+	Items       []RssItem    `xml:"item"`
+}
+
+// RssImage is the artwork of a channel
+type RssImage struct {
+	URL string `xml:"url"`
+}
+
+// metadata returns the channel metadata, preferring the itunes artwork
+func (rc RssChannel) metadata() PodMetadata {
+	m := PodMetadata{Title: strings.TrimSpace(rc.Title),
+		Description: strings.TrimSpace(rc.Description),
+		Link:        firstNonEmpty(rc.Links...)}
+	if rc.ItunesImage != nil {
+		m.Image = strings.TrimSpace(rc.ItunesImage.Href)
+	}
+	if m.Image == "" && rc.Image != nil {
+		m.Image = strings.TrimSpace(rc.Image.URL)
+	}
+	return m
 }
 
 // RssItem represents an individual item in the channel
 type RssItem struct {
-	Title     string       `xml:"title"`
-	Enclosure RssEnclosure `xml:"enclosure"`
-	Subtitle  string       `xml:"itunes:subtitle"`
-	PubDate   RssTime      `xml:"pubDate"`
+	Title         string         `xml:"title"`
+	GUID          string         `xml:"guid,omitempty"`
+	Enclosures    []RssEnclosure `xml:"enclosure"`
+	Subtitle      string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd subtitle,omitempty"`
+	PubDate       RssTime        `xml:"pubDate"`
+	Description   string         `xml:"description,omitempty"`
+	ItunesSummary string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary,omitempty"`
+	ItunesItem
+	MediaContent []MediaContent `xml:"http://search.yahoo.com/mrss/ content,omitempty"`
+}
+
+// MediaContent is a media:content element, some feeds put the audio there
+// instead of in an enclosure
+type MediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr,omitempty"`
+	Medium   string `xml:"medium,attr,omitempty"`
+	FileSize string `xml:"fileSize,attr,omitempty"`
+	Duration string `xml:"duration,attr,omitempty"`
+}
+
+// audio returns the first media:content of the item that is audio
+func (ri RssItem) audio() (MediaContent, bool) {
+	for _, mc := range ri.MediaContent {
+		if strings.HasPrefix(mc.Type, "audio/") || (mc.Type == "" && mc.Medium == "audio") {
+			return mc, true
+		}
+	}
+	return MediaContent{}, false
+}
+
+// itunesNS is the namespace of the itunes podcast tags
+const itunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// ItunesItem holds the fields of the itunes namespace of an item
+type ItunesItem struct {
+	Duration string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration,omitempty"`
+	Author   string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author,omitempty"`
+	Image    *ItunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image,omitempty"`
+	Episode  string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode,omitempty"`
+	Season   string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season,omitempty"`
+}
+
+// ItunesImage is the artwork of an episode
+type ItunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// image returns the itunes:image href of the item, if any
+func (ii ItunesItem) image() string {
+	if ii.Image == nil {
+		return ""
+	}
+	return strings.TrimSpace(ii.Image.Href)
+}
+
+// description returns the show notes, falling back to itunes:summary
+func (ri RssItem) description() string {
+	if d := strings.TrimSpace(ri.Description); d != "" {
+		return d
+	}
+	return strings.TrimSpace(ri.ItunesSummary)
 }
 
 type RssTime struct {
@@ -44,180 +180,1032 @@ type RssTime struct {
 
 // RssEnclosure is the metadata + url of the item
 type RssEnclosure struct {
-	URL string `xml:"url,attr"`
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+}
+
+// enclosure picks the media of an item with several enclosures, preferring
+// audio/mpeg, then any audio and otherwise the first one
+func (ri RssItem) enclosure() (RssEnclosure, bool) {
+	best := -1
+	for i, e := range ri.Enclosures {
+		switch {
+		case e.URL == "":
+			continue
+		case strings.EqualFold(e.Type, "audio/mpeg"):
+			return e, true
+		case best == -1:
+			best = i
+		case isAudio(e.Type) && !isAudio(ri.Enclosures[best].Type):
+			best = i
+		}
+	}
+	if best == -1 {
+		return RssEnclosure{}, false
+	}
+	return ri.Enclosures[best], true
+}
+
+// isAudio reports whether the media type is audio
+func isAudio(mediaType string) bool {
+	return strings.HasPrefix(strings.ToLower(mediaType), "audio/")
+}
+
+// isVideo reports whether the media type is video
+func isVideo(mediaType string) bool {
+	return strings.HasPrefix(strings.ToLower(mediaType), "video/")
+}
+
+// parseDuration reads an itunes:duration, either seconds or [HH:]MM:SS, as
+// seconds. Malformed durations are 0.
+func parseDuration(s string) int64 {
+	var secs int64
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0
+	}
+	for _, p := range parts {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		secs = secs*60 + n
+	}
+	return secs
+}
+
+// parseLength reads an enclosure length in bytes, malformed lengths are 0
+func parseLength(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// parseNumber reads an itunes:episode or itunes:season, malformed numbers
+// are 0
+func parseNumber(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
 }
 
 // Episode is used in the template
 type Episode struct {
-	name     string
-	subtitle string
-	url      string
-	pubDate  time.Time
+	name        string
+	subtitle    string
+	url         string
+	pubDate     time.Time
+	description string
+	duration    int64 // seconds
+	length      int64 // bytes
+	guid        string
+	author      string
+	image       string
+	episode     int
+	season      int
+	mediaType   string
+}
+
+// key identifies the episode, the guid if the feed has one and otherwise the
+// url of the media
+func (e Episode) key() string {
+	if e.guid != "" {
+		return e.guid
+	}
+	return e.url
+}
+
+// dedup keeps one episode per key, some feeds list the same episode twice
+// with slightly different titles. Of duplicates the one with the latest date
+// is kept, in the place of the first.
+func dedup(eps []Episode) []Episode {
+	seen := make(map[string]int, len(eps))
+	out := eps[:0]
+	for _, ep := range eps {
+		if i, ok := seen[ep.key()]; ok {
+			if ep.pubDate.After(out[i].pubDate) {
+				out[i] = ep
+			}
+			continue
+		}
+		seen[ep.key()] = len(out)
+		out = append(out, ep)
+	}
+	return out
 }
 
+// byEpisodeDate sorts episodes newest first, episodes without a date are
+// sorted by title with the highest episode number first
+type byEpisodeDate []Episode
+
+func (e byEpisodeDate) Len() int      { return len(e) }
+func (e byEpisodeDate) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byEpisodeDate) Less(i, j int) bool {
+	if e[i].pubDate.IsZero() && e[j].pubDate.IsZero() {
+		return naturalLess(e[j].name, e[i].name)
+	}
+	return e[i].pubDate.After(e[j].pubDate)
+}
+
+// newest removes duplicates, sorts eps by date and keeps at most limit of
+// them, limit 0 keeps all. Episodes without a date come after the dated ones.
+func newest(eps []Episode, limit int) []Episode {
+	eps = dedup(eps)
+	sort.Stable(byEpisodeDate(eps))
+	if limit > 0 && len(eps) > limit {
+		eps = eps[:limit]
+	}
+	return eps
+}
+
+// parser fetches at most limit episodes of a pod, the request for its feed
+// is conditional on v
 type parser interface {
-	URLs() []Episode
+	URLs(ctx context.Context, v validators, limit int) (fetchResult, error)
 }
 
+// MarshalXML writes the time in the RFC1123Z format rss readers expect, the
+// zero time is left out
+func (rt RssTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if rt.IsZero() {
+		return nil
+	}
+	return e.EncodeElement(rt.Format(time.RFC1123Z), start)
+}
+
+// rssTimeLayouts are tried in order when parsing a pubDate
+var rssTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, _2 Jan 2006 15:04:05 -0700",
+	"Mon, _2 Jan 2006 15:04:05 MST",
+	"_2 Jan 2006 15:04:05 -0700",
+	"_2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+}
+
+// UnmarshalXML parses a pubDate, a date in an unknown format is left as the
+// zero time instead of failing the whole feed
 func (rt *RssTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	var v string
-	d.DecodeElement(&v, &start)
-	parsed, err := time.Parse("Mon, _2 Jan 2006 15:04:05 -0700", v)
+	err := d.DecodeElement(&v, &start)
 	if err != nil {
 		return err
 	}
-	*rt = RssTime{parsed}
+	v = strings.TrimSpace(v)
+	for _, layout := range rssTimeLayouts {
+		parsed, err := time.Parse(layout, v)
+		if err == nil {
+			*rt = RssTime{parsed}
+			return nil
+		}
+	}
+	if v != "" {
+		slog.Debug("unknown date format", "date", v)
+	}
 	return nil
 }
 
 // RssParser implements the parser interface and the  string is the url for the feed
 type RssParser string
 
-// URLs extracts media-links from rss
-func (rp RssParser) URLs() []Episode {
-	res, err := http.Get(string(rp))
+// validators are the cache headers of the last successful fetch of a feed,
+// they are only sent to the url they came from so they are dropped when the
+// feed moves
+type validators struct {
+	url          string
+	etag         string
+	lastModified string
+}
+
+// header returns the headers that make a request for url conditional on
+// v, none when v came from another url
+func (v validators) header(url string) http.Header {
+	h := http.Header{}
+	if v.url != url {
+		return h
+	}
+	if v.etag != "" {
+		h.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		h.Set("If-Modified-Since", v.lastModified)
+	}
+	return h
+}
+
+// fetchResult is what a fetch found out about a feed: the episodes and
+// metadata once it is parsed, and from the response its content type, its
+// cache headers and the url it has moved to permanently, if it has
+type fetchResult struct {
+	eps         []Episode
+	meta        PodMetadata
+	contentType string
+	validators  validators
+	movedTo     string
+}
+
+// errNotModified is returned by fetch when the server answers 304
+var errNotModified = errors.New("not modified")
+
+// fetchOnce downloads the body of url using the shared client, sending
+// header with the request
+func fetchOnce(ctx context.Context, url string, header http.Header) ([]byte, fetchResult, error) {
+	body, res, err := fetchBody(ctx, url, header)
 	if err != nil {
-		log.Printf("%s", err.Error())
-		return nil
+		return nil, res, err
 	}
-	defer res.Body.Close()
+	defer body.Close()
+	bs, err := io.ReadAll(body)
+	if err != nil {
+		return nil, res, err
+	}
+	return bs, res, nil
+}
 
-	bs, err := ioutil.ReadAll(res.Body)
+// fetchBody opens the body of url using the shared client, sending header
+// with the request. The body is decompressed, and reading more than
+// -max-feed-size from it fails with a tooLargeError.
+func fetchBody(ctx context.Context, url string, header http.Header) (io.ReadCloser, fetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Printf("%s", err.Error())
-		return nil
+		return nil, fetchResult{}, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	res, err := client.Do(req)
+	if isTimeout(err) {
+		return nil, fetchResult{}, timeoutError(url)
+	}
+	if err != nil {
+		return nil, fetchResult{}, err
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		return nil, fetchResult{}, errNotModified
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		se := &statusError{url: url, code: res.StatusCode, status: res.Status}
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+			se.retryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		}
+		if body, err := decompressBody(res); err == nil {
+			se.body, _ = io.ReadAll(io.LimitReader(body, maxErrorBody))
+			body.Close()
+		}
+		res.Body.Close()
+		return nil, fetchResult{}, se
+	}
+	fr := fetchResult{contentType: res.Header.Get("Content-Type"),
+		validators: validators{url: url,
+			etag:         res.Header.Get("ETag"),
+			lastModified: res.Header.Get("Last-Modified")}}
+	if moved := movedURL(res); moved != url {
+		fr.movedTo = moved
 	}
 
-	rss := RssFeed{}
-	err = xml.Unmarshal(bs, &rss)
+	if res.ContentLength > *maxFeedSize {
+		res.Body.Close()
+		return nil, fetchResult{}, tooLargeError(url)
+	}
+	body, err := decompressBody(res)
 	if err != nil {
-		log.Printf("%s", err.Error())
-		return nil
+		res.Body.Close()
+		return nil, fetchResult{}, fmt.Errorf("fetching %s: %s", url, err.Error())
 	}
+	return &feedBody{url: url, r: io.LimitReader(body, *maxFeedSize+1), body: body, res: res.Body}, fr, nil
+}
 
-	l := len(rss.Channel.Items)
-	if l > 10 {
-		l = 10
+// feedBody is the body of a feed being read, it fails once more than
+// -max-feed-size is read and closes the response with the decompressor
+type feedBody struct {
+	url  string
+	r    io.Reader
+	n    int64
+	body io.Closer
+	res  io.Closer
+}
+
+func (b *feedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if b.n > *maxFeedSize {
+		return n, tooLargeError(b.url)
 	}
-	eps := make([]Episode, l)
-	for i := 0; i < len(eps); i++ {
-		eps[i] = Episode{rss.Channel.Items[i].Title,
-			rss.Channel.Items[i].Subtitle,
-			rss.Channel.Items[i].Enclosure.URL,
-			rss.Channel.Items[i].PubDate.Time}
+	if isTimeout(err) {
+		return n, timeoutError(b.url)
 	}
-	return eps
+	return n, err
 }
 
-// Pod keeps track and updates the feed
-type Pod struct {
-	name       string
-	parser     parser
-	lastUpdate time.Time
-	image      string
-	eps        []Episode
+func (b *feedBody) Close() error {
+	b.body.Close()
+	return b.res.Close()
 }
 
-// Update the feed items
-func (p *Pod) Update() {
-	eps := p.parser.URLs()
+// decompressBody returns the body of res decoded according to its
+// Content-Encoding. Asking for gzip ourselves turns off the transparent
+// decompression of the transport, so it has to be done here.
+func decompressBody(res *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(res.Body)
+	case "deflate":
+		return zlib.NewReader(res.Body)
+	case "", "identity":
+		return res.Body, nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", res.Header.Get("Content-Encoding"))
+}
 
-	p.lastUpdate = time.Now()
-	sort.Slice(eps, func(i, j int) bool {
-		return eps[i].pubDate.After(eps[j].pubDate)
-	})
-	p.eps = eps
+// timeoutError is returned by fetch when the url did not answer in time
+type timeoutError string
+
+func (e timeoutError) Error() string   { return "timeout fetching " + string(e) }
+func (e timeoutError) Timeout() bool   { return true }
+func (e timeoutError) Temporary() bool { return true }
+
+// tooLargeError is returned by fetch when a feed is larger than
+// -max-feed-size
+type tooLargeError string
+
+func (e tooLargeError) Error() string {
+	return fmt.Sprintf("fetching %s: feed too large, more than %d bytes", string(e), *maxFeedSize)
 }
 
-var m sync.Mutex
-var pods = make(map[string]*Pod)
+// statusError is returned by fetch when the server answers with an error
+type statusError struct {
+	url    string
+	code   int
+	status string
+	// retryAfter is when a 429 or 503 answer asked us to come back, zero
+	// if it had no Retry-After
+	retryAfter time.Time
+	// body is the start of the error page, apis explain the error there
+	body []byte
+}
+
+// maxErrorBody is how much of an error page is kept in a statusError
+const maxErrorBody = 4 << 10
 
-func update() {
-	m.Lock()
-	log.Print("pods: Updating podcasts")
-	for _, pod := range pods {
-		log.Printf("pods:\t%s... ", pod.name)
-		pod.Update()
-		log.Print("Done!")
+func (e *statusError) Error() string {
+	return fmt.Sprintf("fetching %s: %s", e.url, e.status)
+}
+
+// parseRetryAfter reads a Retry-After header, either seconds or an http
+// date, as the time to retry at. Missing or malformed values are zero.
+func parseRetryAfter(v string, now time.Time) time.Time {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return time.Time{}
+		}
+		return now.Add(time.Duration(secs) * time.Second)
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return time.Time{}
 	}
-	m.Unlock()
+	return t
+}
+
+// isTimeout reports whether err was caused by a timeout or deadline
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
 }
 
-func sched() {
-	update()
-	c := time.Tick(1 * time.Hour)
-	for range c {
-		update()
+// URLs extracts at most limit media-links from rss, limit 0 means all of
+// them. The request is conditional on v.
+func (rp RssParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	body, res, err := fetchStream(ctx, string(rp), v.header(string(rp)))
+	if err != nil {
+		return res, err
 	}
+	defer body.Close()
+	res.eps, res.meta, err = parseRss(body, limit)
+	return res, err
 }
 
-func main() {
-	flag.Parse()
-	podcast := &Pod{
-		name:       "Filip & Fredrik",
-		lastUpdate: time.Now(),
-		parser:     RssParser("https://feed.pod.space/filipandfredrik"),
+// episode converts the item to an Episode, the media is taken from the
+// enclosures or else from an audio media:content
+func (ri RssItem) episode() Episode {
+	enc, _ := ri.enclosure()
+	link, length, mediaType, duration := enc.URL, enc.Length, enc.Type, ri.Duration
+	if mc, ok := ri.audio(); link == "" && ok {
+		link, length, mediaType = mc.URL, mc.FileSize, mc.Type
+		if duration == "" {
+			duration = mc.Duration
+		}
 	}
+	return Episode{name: ri.Title,
+		subtitle:    ri.Subtitle,
+		url:         link,
+		pubDate:     ri.PubDate.Time,
+		description: ri.description(),
+		duration:    parseDuration(duration),
+		length:      parseLength(length),
+		guid:        strings.TrimSpace(ri.GUID),
+		author:      strings.TrimSpace(ri.Author),
+		image:       ri.image(),
+		episode:     parseNumber(ri.Episode),
+		season:      parseNumber(ri.Season),
+		mediaType:   strings.TrimSpace(mediaType)}
+}
 
-	pods["filip & fredrik"] = podcast
+// decodeField decodes se, a child element of the channel, into rc
+func (rc *RssChannel) decodeField(d *xml.Decoder, se xml.StartElement) error {
+	if se.Name.Space == itunesNS && se.Name.Local == "image" {
+		rc.ItunesImage = &ItunesImage{}
+		return d.DecodeElement(rc.ItunesImage, &se)
+	}
+	if se.Name.Space != "" {
+		return d.Skip()
+	}
+	switch se.Name.Local {
+	case "title":
+		return d.DecodeElement(&rc.Title, &se)
+	case "description":
+		return d.DecodeElement(&rc.Description, &se)
+	case "link":
+		var l string
+		err := d.DecodeElement(&l, &se)
+		rc.Links = append(rc.Links, l)
+		return err
+	case "image":
+		rc.Image = &RssImage{}
+		return d.DecodeElement(rc.Image, &se)
+	}
+	return d.Skip()
+}
 
-	aosPod := &Pod{
-		name:       "Alex & Sigge",
-		lastUpdate: time.Now(),
-		parser:     RssParser("http://alexosigge.libsyn.com/rss"),
+// reopen returns the start tags that put a new decoder inside the channel
+// of the rss element root, with the namespaces declared on root
+func reopen(root xml.StartElement) []byte {
+	var b bytes.Buffer
+	b.WriteString("<rss")
+	for _, a := range root.Attr {
+		var name string
+		switch {
+		case a.Name.Space == "xmlns":
+			name = "xmlns:" + a.Name.Local
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			name = "xmlns"
+		default:
+			continue
+		}
+		b.WriteString(" " + name + `="`)
+		xml.EscapeText(&b, []byte(a.Value))
+		b.WriteString(`"`)
 	}
+	b.WriteString("><channel>")
+	return b.Bytes()
+}
+
+// itemEnd matches the end of an item that was closed properly
+var itemEnd = regexp.MustCompile(`(</item\s*>|/>)$`)
+
+// itemTail is how many of the last bytes read an itemReader keeps
+const itemTail = 32
 
-	pods["alex & sigge"] = aosPod
+// itemReader is the input of the rss decoder. It is an io.ByteReader so the
+// decoder does not buffer ahead of it, which lets parseRss skip the rest of
+// a broken item in the stream and carry on with a new decoder.
+type itemReader struct {
+	r io.ByteReader
+	// pending is read before r, the start tags that reopen the channel or
+	// bytes put back
+	pending []byte
+	// n is the number of bytes read, last has the last of them at their
+	// offset modulo itemTail
+	n    int64
+	last [itemTail]byte
+	// err is the first error reading r other than io.EOF, the feed being
+	// too large or timing out
+	err error
+}
+
+func newItemReader(r io.Reader) *itemReader {
+	return &itemReader{r: bufio.NewReader(r)}
+}
 
-	kodsnackPod := &Pod{
-		parser:     RssParser("https://kodsnack.libsyn.com/rss"),
-		lastUpdate: time.Now(),
-		name:       "Kodsnack",
+func (ir *itemReader) ReadByte() (byte, error) {
+	var c byte
+	if len(ir.pending) > 0 {
+		c, ir.pending = ir.pending[0], ir.pending[1:]
+	} else {
+		var err error
+		c, err = ir.r.ReadByte()
+		if err != nil {
+			if err != io.EOF && ir.err == nil {
+				ir.err = err
+			}
+			return 0, err
+		}
 	}
+	ir.last[ir.n%itemTail] = c
+	ir.n++
+	return c, nil
+}
 
-	pods["kodsnack"] = kodsnackPod
+func (ir *itemReader) Read(p []byte) (int, error) {
+	for i := range p {
+		c, err := ir.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = c
+	}
+	return len(p), nil
+}
 
-	gotimePod := &Pod{
-		name:       "Go Time",
-		lastUpdate: time.Now(),
-		parser:     RssParser("https://changelog.com/gotime/feed"),
+// window returns the bytes read from offset from up to to, from must not be
+// more than itemTail behind
+func (ir *itemReader) window(from, to int64) []byte {
+	from = max(from, 0, ir.n-itemTail)
+	bs := make([]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		bs = append(bs, ir.last[i%itemTail])
 	}
+	return bs
+}
 
-	pods["go time"] = gotimePod
+// unread puts back the bytes read after offset at, the ones a decoder that
+// stopped at at has not used
+func (ir *itemReader) unread(at int64) {
+	ir.pending = append(ir.window(at, ir.n), ir.pending...)
+	ir.n = at
+}
+
+// reopen puts the start tags that reopen the channel before the rest of
+// the input, for a new decoder
+func (ir *itemReader) reopen(prefix []byte) {
+	ir.pending = append(append([]byte{}, prefix...), ir.pending...)
+}
 
-	seradioPod := &Pod{
-		name:       "SE Radio",
-		lastUpdate: time.Now(),
-		parser:     RssParser("https://www.se-radio.net/feed/podcast/"),
+// skipItem discards the input from offset at up to and including the next
+// </item> and reports whether there was one
+func (ir *itemReader) skipItem(at int64) bool {
+	ir.unread(at)
+	end := []byte("</item>")
+	matched := 0
+	for matched < len(end) {
+		c, err := ir.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch {
+		case c == end[matched]:
+			matched++
+		case c == end[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	return true
+}
+
+// countItems counts the item start tags in the input from offset at to the
+// end
+func (ir *itemReader) countItems(at int64) int {
+	ir.unread(at)
+	start := []byte("<item")
+	n, matched := 0, 0
+	for {
+		c, err := ir.ReadByte()
+		if err != nil {
+			return n
+		}
+		if matched == len(start) {
+			if c == '>' || c == ' ' {
+				n++
+			}
+			matched = 0
+		}
+		switch {
+		case c == start[matched]:
+			matched++
+		case c == start[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+}
+
+// parseRss extracts at most limit episodes and the channel metadata from the
+// rss document read from r. The document is decoded as it is read, one item
+// at a time, so memory is proportional to limit rather than to the feed. A
+// broken item is skipped, the other episodes are then returned with a
+// skippedError. Failing to read r fails the whole feed.
+func parseRss(r io.Reader, limit int) ([]Episode, PodMetadata, error) {
+	ir := newItemReader(utf8Reader(r))
+	var ch RssChannel
+	var eps []Episode
+	var prefix []byte // set once the root element is read
+	var skipped int
+	var first error
+
+	d := utf8Decoder(ir)
+	var start int64 // the offset in the input where d started
+read:
+	for {
+		t, err := d.Token()
+		if err == io.EOF || ir.err != nil {
+			break
+		}
+		if err != nil {
+			if prefix == nil {
+				return nil, PodMetadata{}, err
+			}
+			// the items after this are lost
+			skipped += ir.countItems(start + d.InputOffset())
+			first = err
+			break
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if prefix == nil {
+			if se.Name.Local != "rss" {
+				return nil, PodMetadata{}, fmt.Errorf("expected element type <rss> but have <%s>", se.Name.Local)
+			}
+			prefix = reopen(se)
+			continue
+		}
+
+		switch se.Name.Local {
+		case "rss", "channel":
+		case "item":
+			var it RssItem
+			err = d.DecodeElement(&it, &se)
+			if ir.err != nil {
+				break read
+			}
+			at := start + d.InputOffset()
+			if err == nil && !itemEnd.Match(ir.window(at-16, at)) {
+				// the lenient decoder closes every open element on a
+				// mismatched end tag, the item then ends early and the
+				// error only comes after the channel
+				err = fmt.Errorf("item is not closed by </item>")
+			}
+			if err == nil {
+				// items without any media are not episodes
+				if ep := it.episode(); ep.url != "" {
+					eps = append(eps, ep)
+				}
+				// only the newest limit episodes are kept, feeds are
+				// not always sorted so the rest of the items are read
+				if limit > 0 && len(eps) >= 2*limit {
+					eps = newest(eps, limit)
+				}
+				continue
+			}
+			slog.Warn("skipping broken item", "err", err)
+			skipped++
+			if first == nil {
+				first = err
+			}
+			// carry on after the broken item with a new decoder
+			if !ir.skipItem(at) {
+				break read
+			}
+			ir.reopen(prefix)
+			start = ir.n
+			d = utf8Decoder(ir)
+		default:
+			err = ch.decodeField(d, se)
+			if err != nil && ir.err == nil {
+				skipped += ir.countItems(start + d.InputOffset())
+				first = err
+				break read
+			}
+		}
+	}
+	if ir.err != nil {
+		return nil, PodMetadata{}, ir.err
 	}
+	return parsedRss(eps, ch, limit, skipped, first)
+}
+
+// parsedRss is the result of parseRss, err is the first error when items
+// were skipped
+func parsedRss(eps []Episode, ch RssChannel, limit, skipped int, err error) ([]Episode, PodMetadata, error) {
+	if err != nil {
+		err = &skippedError{skipped: skipped, total: len(eps) + skipped, err: err}
+	}
+	return newest(eps, limit), ch.metadata(), err
+}
+
+// Pod keeps track and updates the feed
+type Pod struct {
+	name        string
+	url         string
+	kind        string
+	parser      parser
+	lastUpdate  time.Time
+	meta        PodMetadata
+	eps         []Episode
+	maxEpisodes int
+
+	lastAttempt   time.Time
+	lastError     error
+	lastErrorTime time.Time
+	failures      int
+	nextAttempt   time.Time
+	// disabled is set after -max-failures failures in a row, the pod is
+	// then skipped until it is enabled through the api
+	disabled bool
+	// rateLimited is when the feed host asked us to come back with a
+	// Retry-After, the pod is skipped until then
+	rateLimited time.Time
 
-	pods["se radio"] = seradioPod
+	// config is the entry the pod was created from, url differs from its
+	// URL once the feed has moved permanently
+	config Config
+	// added is set on pods added through the api or an opml import, they
+	// are restored from the saved state without being in the config
+	added bool
 
-	bikeshedFM := &Pod{
-		name:       "The Bike Shed",
-		lastUpdate: time.Now(),
-		parser:     RssParser("https://rss.simplecast.com/podcasts/282/rss"),
+	// fetching is held while the pod is updated, cache and moved are only
+	// used by the goroutine holding it
+	fetching sync.Mutex
+	cache    validators
+	moved    string
+}
+
+// maxBackoff caps how long a failing pod is left alone
+const maxBackoff = 24 * time.Hour
+
+// backoff returns how long to wait before retrying a pod that has failed
+// the given number of times in a row, doubling from an hour
+func backoff(failures int) time.Duration {
+	d := time.Hour
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
 	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
 
-	pods["bikeshed"] = bikeshedFM
+// resolveURLs makes relative and protocol relative episode urls absolute
+// using the url of the feed, absolute urls are left as they are
+func resolveURLs(feed string, eps []Episode) {
+	base, err := url.Parse(feed)
+	if err != nil {
+		return
+	}
+	for i := range eps {
+		ref, err := url.Parse(eps[i].url)
+		if err != nil || ref.IsAbs() || eps[i].url == "" {
+			continue
+		}
+		eps[i].url = base.ResolveReference(ref).String()
+	}
+}
+
+// errNoEpisodes is returned by fetch when a feed parsed but had no episodes
+var errNoEpisodes = errors.New("feed has no episodes")
 
-	onTheMetal := &Pod{
-		name:       "On The Metal",
-		lastUpdate: time.Now(),
-		parser:     RssParser("https://feeds.transistor.fm/on-the-metal-0294649e-ec23-4eab-975a-9eb13fd94e06"),
+// fetch gets the episodes of the feed sorted newest first and the metadata
+// of the podcast, a feed without episodes is an error. The request is
+// conditional on the cache headers of the last successful fetch and
+// errNotModified is returned if the feed is unchanged.
+func (p *Pod) fetch(ctx context.Context) ([]Episode, PodMetadata, error) {
+	res, err := p.parser.URLs(ctx, p.cache, p.maxEpisodes)
+	eps := res.eps
+	if err != nil && (!isPartial(err) || len(eps) == 0) {
+		return nil, res.meta, err
+	}
+	if len(eps) == 0 {
+		return nil, res.meta, errNoEpisodes
 	}
+	resolveURLs(firstNonEmpty(res.movedTo, p.url), eps)
+	sort.Stable(byEpisodeDate(eps))
+	if res.validators != (validators{}) {
+		// a feed served from fetchCache has none, the old ones still hold
+		p.cache = res.validators
+	}
+	p.moved = res.movedTo
+	return eps, res.meta, err
+}
+
+// moveTo changes the url of the pod, the caller must hold the store lock
+func (p *Pod) moveTo(u string) {
+	if u == p.url {
+		return
+	}
+	p.url = u
+	c := p.config
+	c.URL = u
+	p.parser = c.parser()
+}
 
-	pods["on the metal"] = onTheMetal
+// record the result of a fetch. On failure the previous episodes are kept
+// and the error is recorded on the pod, when only some items were skipped
+// the episodes are updated and the error is kept as a note. The caller must
+// hold the store lock.
+func (p *Pod) record(eps []Episode, meta PodMetadata, err error, now time.Time) {
+	p.lastAttempt = now
+	if err == errNotModified {
+		eps, meta, err = p.eps, p.meta, nil
+	}
+	var se *statusError
+	if errors.As(err, &se) && !se.retryAfter.IsZero() {
+		// being rate limited is not a failure of the feed
+		p.lastError = err
+		p.lastErrorTime = now
+		p.rateLimited = se.retryAfter
+		p.nextAttempt = se.retryAfter
+		slog.Warn("rate limited", "podcast", p.name, "url", p.url, "until", se.retryAfter)
+		return
+	}
+	p.rateLimited = time.Time{}
+	if err != nil && !isPartial(err) {
+		p.lastError = err
+		p.lastErrorTime = now
+		p.failures++
+		p.nextAttempt = now.Add(backoff(p.failures))
+		if *maxFailures > 0 && p.failures >= *maxFailures && !p.disabled {
+			p.disabled = true
+			slog.Warn("podcast disabled", "podcast", p.name, "failures", p.failures)
+		}
+		return
+	}
+	if p.moved != "" && p.moved != p.url {
+		slog.Info("feed moved permanently", "podcast", p.name, "from", p.url, "to", p.moved)
+		p.moveTo(p.moved)
+	}
+	p.moved = ""
+	p.lastUpdate = now
+	p.eps = eps
+	p.meta = meta
+	p.lastError = err
+	if err != nil {
+		p.lastErrorTime = now
+	}
+	p.failures = 0
+	p.nextAttempt = time.Time{}
+}
 
-	signalsAndThreads := &Pod{
-		name:       "Signals and Threads",
-		lastUpdate: time.Now(),
-		parser:     RssParser("https://feeds.simplecast.com/L9810DOa"),
+// enable resets the failures of a disabled pod so it is updated again, the
+// caller must hold the store lock
+func (p *Pod) enable() {
+	if p.disabled {
+		slog.Warn("podcast enabled again", "podcast", p.name)
 	}
+	p.disabled = false
+	p.failures = 0
+	p.nextAttempt = time.Time{}
+}
 
-	pods["signals and threads"] = signalsAndThreads
+// sched updates all pods at startup and then runs every pod on its own
+// ticker of d, the updates share a queue of -workers. Pods added or removed
+// at runtime are picked up within a minute.
+func sched(ctx context.Context, d time.Duration) {
+	store.Update(ctx, false)
 
-	go sched()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	queue := store.updateQueue(ctx, &wg)
+	running := make(map[*Pod]context.CancelFunc)
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		all := store.All()
+		live := make(map[*Pod]bool, len(all))
+		for _, pod := range all {
+			live[pod] = true
+			if _, ok := running[pod]; ok {
+				continue
+			}
+			pctx, cancel := context.WithCancel(ctx)
+			running[pod] = cancel
+			wg.Add(1)
+			go func(pod *Pod) {
+				defer wg.Done()
+				store.schedule(pctx, pod, d, queue)
+			}(pod)
+		}
+		for pod, cancel := range running {
+			if !live[pod] {
+				cancel()
+				delete(running, pod)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *verbose {
+		*logLevel = "debug"
+	}
+	logger, err := newLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pods: %s\n", err.Error())
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	client.Timeout = *timeout
+	if *maxIdle < 0 {
+		fatal("max-idle can not be negative")
+	}
+	client.Transport = newTransport(*maxIdle)
+	fetchCache = NewFetchCache(*cacheTTL)
+	if *cacheDir != "" {
+		if err := os.MkdirAll(*cacheDir, 0755); err != nil {
+			fatal("creating cache directory", "path", *cacheDir, "err", err)
+		}
+	}
+	if *port != "" {
+		slog.Warn("-port is deprecated, use -addr")
+		*addr = *port
+		if !strings.Contains(*addr, ":") {
+			*addr = ":" + *addr
+		}
+	}
+	if _, _, err := net.SplitHostPort(*addr); err != nil {
+		fatal("invalid -addr", "addr", *addr, "err", err)
+	}
+	if *workers < 1 {
+		fatal("workers must be at least 1")
+	}
+	if *retries < 1 {
+		fatal("retries must be at least 1")
+	}
+	if *maxFeedSize < 1 {
+		fatal("max-feed-size must be at least 1")
+	}
+	if *maxFailures < 0 {
+		fatal("max-failures can not be negative")
+	}
+	if *interval < time.Minute {
+		fatal("interval is shorter than 1m", "interval", *interval)
+	}
+	if *token == "" {
+		slog.Warn("no -token set, anyone who can reach the server can add and remove podcasts")
+	}
+	cfgs := defaultConfig
+	if *config != "" {
+		cfgs, err = loadConfig(*config)
+		if err != nil {
+			fatal("loading config", "err", err)
+		}
+	}
+	addPods(cfgs)
+	switch {
+	case *state != "" && *dbPath != "":
+		fatal("-state and -db can not both be set")
+	case *state != "":
+		stateStore = NewFileStore(*state)
+	case *dbPath != "":
+		bs, err := NewBoltStore(*dbPath)
+		if err != nil {
+			fatal("opening database", "path", *dbPath, "err", err)
+		}
+		defer bs.Close()
+		stateStore = bs
+	}
+	if err := loadState(); err != nil {
+		fatal("loading state", "err", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdown = ctx
+
+	if *checkFeeds {
+		checkPods(ctx)
+	}
+	done := make(chan struct{})
+	go func() {
+		sched(ctx, *interval)
+		close(done)
+	}()
 	http.HandleFunc("/", index)
 	http.HandleFunc("/forceupdate", func(w http.ResponseWriter, r *http.Request) {
 		writeflush := func(s string) {
@@ -228,60 +1216,246 @@ func main() {
 		}
 		io.WriteString(w, strings.Repeat(" ", 1025))
 		writeflush("Starting update... ")
-		update()
+		store.Update(r.Context(), true)
 		writeflush("Done")
 	})
-	http.HandleFunc("/feed.json", func(w http.ResponseWriter, r *http.Request) {
-		data := GetPods()
-		j, _ := json.Marshal(data)
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(j)
-	})
-	http.ListenAndServe(*port, nil)
+	http.HandleFunc("/feed.json", feedJSON)
+	http.HandleFunc("/api/pods", apiPods)
+	http.HandleFunc("/api/podcasts", apiPodcasts)
+	http.HandleFunc("/api/podcasts/", apiPodcasts)
+	http.HandleFunc("/api/add", apiAdd)
+	http.HandleFunc("/pods", podsHandler)
+	http.HandleFunc("/pods/", podsHandler)
+	http.HandleFunc("/import-opml", importOPML)
+	http.HandleFunc("/export.opml", exportOPML)
+	http.HandleFunc("/all.rss", allRss)
+	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/healthz", healthz)
+	http.HandleFunc("/health", health)
+	http.HandleFunc("/search", searchHandler)
+	http.HandleFunc("/download", download)
+	srv := &http.Server{Addr: *addr}
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			fatal("serving http", "addr", *addr, "err", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+	err = srv.Shutdown(sctx)
+	if err != nil {
+		slog.Error("shutdown", "err", err)
+	}
+	select {
+	case <-done:
+	case <-sctx.Done():
+		slog.Warn("update did not stop in time")
+	}
 }
 
 func GetPods() []TemplatePod {
 	var data []TemplatePod
 
-	m.Lock()
-	for name, pod := range pods {
+	store.RLock()
+	for name, pod := range store.pods {
 		tp := TemplatePod{Name: name,
-			LastUpdate: pod.lastUpdate.Format("2006-01-02 15:04"),
-			Episodes:   make([]TemplateEpisode, len(pod.eps))}
+			LastUpdate:  pod.lastUpdate.Format("2006-01-02 15:04"),
+			Description: plainText(pod.meta.Description),
+			Image:       pod.meta.Image,
+			Episodes:    make([]TemplateEpisode, len(pod.eps))}
+		if isPartial(pod.lastError) {
+			// the update went through, only some items were skipped
+			tp.Warning = pod.lastError.Error()
+			tp.LastErrorTime = pod.lastErrorTime.Format("2006-01-02 15:04")
+		} else if pod.lastError != nil {
+			tp.LastError = pod.lastError.Error()
+			tp.LastErrorTime = pod.lastErrorTime.Format("2006-01-02 15:04")
+			tp.Failures = pod.failures
+		}
+		tp.Disabled = pod.disabled
+		if pod.rateLimited.After(time.Now()) {
+			tp.RateLimited = pod.rateLimited.Format("2006-01-02 15:04")
+		}
+		if !pod.nextAttempt.IsZero() {
+			tp.NextAttempt = pod.nextAttempt.Format("2006-01-02 15:04")
+		}
 		for i := range pod.eps {
-			tp.Episodes[i] = TemplateEpisode{Title: pod.eps[i].name, URL: pod.eps[i].url}
+			tp.Episodes[i] = TemplateEpisode{Title: pod.eps[i].name,
+				URL:         pod.eps[i].url,
+				Description: sanitizeDescription(pod.eps[i].description),
+				Author:      pod.eps[i].author,
+				Image:       pod.eps[i].image,
+				Episode:     pod.eps[i].episode,
+				Season:      pod.eps[i].season,
+				Duration:    pod.eps[i].duration,
+				Size:        pod.eps[i].length,
+				MediaType:   pod.eps[i].mediaType}
+			if !pod.eps[i].pubDate.IsZero() {
+				tp.Episodes[i].PubDate = pod.eps[i].pubDate.Format("Jan 2, 2006")
+			}
 		}
 		data = append(data, tp)
 	}
-	m.Unlock()
+	store.RUnlock()
 	return data
 }
 
 func index(w http.ResponseWriter, r *http.Request) {
-	t, err := template.New("index").Parse(indextemplate)
+	t, err := template.New("index").Funcs(templateFuncs).Parse(indextemplate)
 	if err != nil {
 		fmt.Fprint(w, err.Error())
-		log.Print(err.Error())
+		slog.Error("rendering index", "err", err)
 		return
 	}
-	data := GetPods()
+	data := IndexData{Pods: GetPods(), Interval: *interval}
+	for _, p := range data.Pods {
+		if p.LastUpdate > data.LastUpdate {
+			data.LastUpdate = p.LastUpdate
+		}
+	}
+	paginate(&data, queryInt(r, "page", 1), queryInt(r, "size", *limit))
 	err = t.Execute(w, data)
 	if err != nil {
-		log.Print(err.Error())
+		slog.Error("rendering index", "err", err)
 	}
 }
 
 // TemplateEpisode is for the html template
 type TemplateEpisode struct {
-	Title string
-	URL   string
+	Title       string        `json:"title"`
+	URL         string        `json:"url"`
+	PubDate     string        `json:"pub_date,omitempty"`
+	Description template.HTML `json:"description,omitempty"`
+	Author      string        `json:"author,omitempty"`
+	Image       string        `json:"image,omitempty"`
+	Episode     int           `json:"episode,omitempty"`
+	Season      int           `json:"season,omitempty"`
+	Duration    int64         `json:"duration,omitempty"`
+	Size        int64         `json:"size,omitempty"`
+	MediaType   string        `json:"media_type,omitempty"`
+}
+
+// templateFuncs are the helpers available in indextemplate
+var templateFuncs = template.FuncMap{
+	"duration": formatDuration,
+	"size":     formatSize,
+	"video":    isVideo,
+}
+
+// formatDuration formats seconds as "1h 42m", or "42m" and "30s" for short
+// episodes
+func formatDuration(secs int64) string {
+	if secs <= 0 {
+		return ""
+	}
+	h, m := secs/3600, secs%3600/60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%ds", secs)
+}
+
+// formatSize formats bytes as "98 MB"
+func formatSize(bytes int64) string {
+	if bytes <= 0 {
+		return ""
+	}
+	units := []string{"B", "kB", "MB", "GB"}
+	n, i := float64(bytes), 0
+	for n >= 1000 && i < len(units)-1 {
+		n /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.0f %s", n, units[i])
+}
+
+// IndexData is the root of the html template
+type IndexData struct {
+	Pods       []TemplatePod
+	LastUpdate string
+	Interval   time.Duration
+
+	// Size is the number of episodes per page, 0 shows all of them
+	Size     int
+	Page     int
+	PrevPage int
+	NextPage int
+}
+
+// queryInt reads the query parameter name of r as a number, def is used if
+// it is missing or not a number
+func queryInt(r *http.Request, name string, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// paginate keeps the given page of size episodes of every pod in data. The
+// page is clamped to the pages there are, and pods with fewer pages show
+// their last one.
+func paginate(data *IndexData, page, size int) {
+	if size <= 0 {
+		data.Page = 1
+		return
+	}
+	pages := 1
+	for _, p := range data.Pods {
+		if n := (len(p.Episodes) + size - 1) / size; n > pages {
+			pages = n
+		}
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > pages {
+		page = pages
+	}
+	data.Size, data.Page = size, page
+	if page > 1 {
+		data.PrevPage = page - 1
+	}
+	if page < pages {
+		data.NextPage = page + 1
+	}
+
+	for i, p := range data.Pods {
+		start := (page - 1) * size
+		if last := (len(p.Episodes) - 1) / size * size; start > last && last >= 0 {
+			start = last
+		}
+		end := start + size
+		if end > len(p.Episodes) {
+			end = len(p.Episodes)
+		}
+		data.Pods[i].Episodes = p.Episodes[start:end]
+	}
 }
 
 // TemplatePod is for the html template
 type TemplatePod struct {
-	Name       string
-	LastUpdate string
-	Episodes   []TemplateEpisode
+	Name       string            `json:"name"`
+	LastUpdate string            `json:"last_update"`
+	Episodes   []TemplateEpisode `json:"episodes"`
+
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+
+	LastError     string `json:"last_error,omitempty"`
+	Warning       string `json:"warning,omitempty"`
+	LastErrorTime string `json:"last_error_time,omitempty"`
+	Failures      int    `json:"failures,omitempty"`
+	NextAttempt   string `json:"next_attempt,omitempty"`
+	Disabled      bool   `json:"disabled,omitempty"`
+	RateLimited   string `json:"rate_limited,omitempty"`
 }
 
 var indextemplate = `
@@ -303,20 +1477,37 @@ var indextemplate = `
 					font-size: 18px;
 					line-height: 1.6;
 				} 
+				footer {
+					width: 100%;
+					font-size: 14px;
+				}
 			</style>
 		</head>
 		<body>
-		{{ range . }}
+		{{ range .Pods }}
 			<div style="width: 600px">
-				<h3><strong>{{ .Name }}</strong></h3>
+				<h3>{{ if .Image }}<img src="{{ .Image }}" alt="" width="64" height="64" style="vertical-align: middle" /> {{ end }}<strong>{{ .Name }}</strong></h3>
+				{{ if .Description }}<p><small>{{ .Description }}</small></p>{{ end }}
 				<i>{{ .LastUpdate }}</i><br />
+				{{ if .RateLimited }}<i style="color: #a00">Rate limited until {{ .RateLimited }}</i><br />
+				{{ else if .Warning }}<i style="color: #a60">Updated {{ .LastErrorTime }} with problems: {{ .Warning }}</i><br />
+				{{ else if .LastError }}<i style="color: #a00">Update failed {{ .LastErrorTime }}{{ if gt .Failures 1 }} ({{ .Failures }} times in a row){{ end }}: {{ .LastError }}{{ if .Disabled }}, updates are disabled{{ else if .NextAttempt }}, next attempt {{ .NextAttempt }}{{ end }}</i><br />{{ end }}
 				<ul>
 				{{ range .Episodes }}
-					<li><a href="{{ .URL }}" target="_blank">{{ .Title }}</a></li>
+					<li>{{ if .Episode }}<small>{{ if .Season }}S{{ .Season }} {{ end }}E{{ .Episode }}</small> {{ end }}<a href="{{ .URL }}" target="_blank">{{ .Title }}</a>{{ if video .MediaType }} <small>(video)</small>{{ end }}{{ if .PubDate }} <small>Published: {{ .PubDate }}</small>{{ end }}
+					{{ if .Author }}<small>by {{ .Author }}</small>{{ end }}
+					{{ if or .Duration .Size }}<small>{{ duration .Duration }}{{ if and .Duration .Size }} &middot; {{ end }}{{ size .Size }}</small>{{ end }}
+					{{ if .Description }}<details><summary>Show notes</summary>{{ .Description }}</details>{{ end }}
+					</li>
 				{{ end }}	
 				</ul>
 			</div>
 		{{ end }}
-		
+			<footer>
+				{{ if .PrevPage }}<a href="?page={{ .PrevPage }}&amp;size={{ .Size }}">&larr; Newer</a>{{ end }}
+				{{ if .NextPage }}<a href="?page={{ .NextPage }}&amp;size={{ .Size }}">Older &rarr;</a>{{ end }}
+				<br />
+				<i>Last update {{ .LastUpdate }}, updating every {{ .Interval }}</i>
+			</footer>
 	 </body>
 	</html>`
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetStore gives the test an empty store and puts the old one back after
+func resetStore(t *testing.T) {
+	t.Helper()
+	old := store
+	store = NewPodStore()
+	t.Cleanup(func() { store = old })
+}
+
+// setRetries sets -retries for the test
+func setRetries(t *testing.T, n int) {
+	t.Helper()
+	old := *retries
+	*retries = n
+	t.Cleanup(func() { *retries = old })
+}
+
+// setTimeout sets the client timeout for the test
+func setTimeout(t *testing.T, d time.Duration) {
+	t.Helper()
+	old := client.Timeout
+	client.Timeout = d
+	t.Cleanup(func() { client.Timeout = old })
+}
+
+// serve starts a test server answering every request with h
+func serve(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serveFile starts a test server answering every request with the file
+// in testdata
+func serveFile(t *testing.T, name string) *httptest.Server {
+	return serve(t, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/"+name)
+	})
+}
+
+// hostTransport sends every request to the test server at target, for
+// parsers with hardcoded api hosts. The host asked for is kept in the
+// X-Forwarded-Host header.
+type hostTransport struct {
+	target *url.URL
+}
+
+func (ht hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Forwarded-Host", req.URL.Host)
+	req.URL.Scheme = ht.target.Scheme
+	req.URL.Host = ht.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// serveHosts starts a test server answering every request of the client,
+// whatever host it is for, with h
+func serveHosts(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := serve(t, h)
+	target, _ := url.Parse(srv.URL)
+	old := client.Transport
+	client.Transport = hostTransport{target: target}
+	t.Cleanup(func() { client.Transport = old })
+	return srv
+}
+
+// rssFeed returns an rss document with n episodes published a day apart,
+// oldest first, episode i is called "Avsnitt i"
+func rssFeed(n int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel><title>Test</title>`)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, `<item><title>Avsnitt %d</title><guid>ep-%d</guid><pubDate>%s</pubDate><enclosure url="https://example.com/%d.mp3" type="audio/mpeg" /></item>`,
+			i, i, start.AddDate(0, 0, i).Format(time.RFC1123Z), i)
+	}
+	b.WriteString(`</channel></rss>`)
+	return b.String()
+}
+
+// fetchFixture fetches the file in testdata as a feed of type kind
+func fetchFixture(t *testing.T, kind, name string) []Episode {
+	t.Helper()
+	srv := serveFile(t, name)
+	pod := newPod(Config{Name: "Test", URL: srv.URL + "/" + name, Type: kind})
+	eps, _, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eps
+}
+
+// byGUID returns eps by their guid
+func byGUID(eps []Episode) map[string]Episode {
+	m := make(map[string]Episode, len(eps))
+	for _, ep := range eps {
+		m[ep.guid] = ep
+	}
+	return m
+}
+
+// titles returns the titles of eps
+func titles(eps []Episode) []string {
+	ts := make([]string, len(eps))
+	for i, ep := range eps {
+		ts[i] = ep.name
+	}
+	return ts
+}
+
+func TestEpisodeLimit(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, rssFeed(5))
+	})
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"more items than the limit", 3, []string{"Avsnitt 5", "Avsnitt 4", "Avsnitt 3"}},
+		{"fewer items than the limit", 10, []string{"Avsnitt 5", "Avsnitt 4", "Avsnitt 3", "Avsnitt 2", "Avsnitt 1"}},
+		{"no limit", 0, []string{"Avsnitt 5", "Avsnitt 4", "Avsnitt 3", "Avsnitt 2", "Avsnitt 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newPod(Config{Name: "Test", URL: srv.URL, Type: "rss", MaxEpisodes: &tt.limit})
+			eps, _, err := pod.fetch(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(eps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescription(t *testing.T) {
+	eps := fetchFixture(t, "rss", "description.rss")
+	tests := []struct {
+		name, description, subtitle string
+	}{
+		{"Both", "<p>The <b>description</b></p>", "The subtitle"},
+		{"Summary only", "Only a summary", ""},
+		{"Neither", "", ""},
+	}
+	if len(eps) != len(tests) {
+		t.Fatalf("got %d episodes, want %d", len(eps), len(tests))
+	}
+	for i, tt := range tests {
+		ep := eps[i]
+		if ep.name != tt.name {
+			t.Errorf("episode %d is %q, want %q", i, ep.name, tt.name)
+		}
+		if ep.description != tt.description {
+			t.Errorf("%s: description %q, want %q", tt.name, ep.description, tt.description)
+		}
+		if ep.subtitle != tt.subtitle {
+			t.Errorf("%s: subtitle %q, want %q", tt.name, ep.subtitle, tt.subtitle)
+		}
+	}
+}
+
+func TestIndexShowsDescription(t *testing.T) {
+	resetStore(t)
+	pod := newPod(Config{Name: "Test", URL: "https://example.com/rss", Type: "rss"})
+	pod.eps = fetchFixture(t, "rss", "description.rss")
+	store.Add("Test", pod)
+
+	w := httptest.NewRecorder()
+	index(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"<details><summary>Show notes</summary><p>The <b>description</b></p></details>",
+		"<details><summary>Show notes</summary>Only a summary</details>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("index is missing %s", want)
+		}
+	}
+	if n := strings.Count(body, "<details>"); n != 2 {
+		t.Errorf("got %d details blocks, want 2", n)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	setTimeout(t, 50*time.Millisecond)
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	_, err := fetch(context.Background(), srv.URL+"/slow.rss")
+	if want := "timeout fetching " + srv.URL + "/slow.rss"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestSortByDate(t *testing.T) {
+	tests := []struct {
+		file  string
+		limit int
+		want  []string
+	}{
+		{"dated.rss", 2, []string{"Fourth", "Third"}},
+		{"dated.rss", 0, []string{"Fourth", "Third", "Second", "First"}},
+		{"undated.rss", 0, []string{"Avsnitt 11", "Avsnitt 10", "Avsnitt 9"}},
+		{"mixed-dates.rss", 0, []string{"RFC 3339", "RFC 822", "No weekday", "Single digit day", "RFC 1123", "Undated", "Garbled"}},
+	}
+	for _, tt := range tests {
+		srv := serveFile(t, tt.file)
+		pod := newPod(Config{Name: "Test", URL: srv.URL + "/" + tt.file, Type: "rss", MaxEpisodes: &tt.limit})
+		eps, _, err := pod.fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %s", tt.file, err)
+		}
+		if got := titles(eps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s, limit %d: got %q, want %q", tt.file, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"45", 45},
+		{"2700", 2700},
+		{"05:30", 330},
+		{"1:02:03", 3723},
+		{" 1:00:00 ", 3600},
+		{"1:2:3:4", 0},
+		{"-5", 0},
+		{"1h", 0},
+	}
+	for _, tt := range tests {
+		if got := parseDuration(tt.in); got != tt.want {
+			t.Errorf("parseDuration(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDurationAndSize(t *testing.T) {
+	eps := make(map[string]Episode)
+	for _, ep := range fetchFixture(t, "rss", "durations.rss") {
+		eps[ep.name] = ep
+	}
+	tests := []struct {
+		name           string
+		duration, size string
+	}{
+		{"Clock", "1h 2m", "99 MB"},
+		{"Seconds", "45m", "1 kB"},
+		{"None", "", ""},
+	}
+	for _, tt := range tests {
+		ep := eps[tt.name]
+		if got := formatDuration(ep.duration); got != tt.duration {
+			t.Errorf("%s: duration %q, want %q", tt.name, got, tt.duration)
+		}
+		if got := formatSize(ep.length); got != tt.size {
+			t.Errorf("%s: size %q, want %q", tt.name, got, tt.size)
+		}
+	}
+	if got := formatDuration(42); got != "42s" {
+		t.Errorf("formatDuration(42) = %q, want 42s", got)
+	}
+}
+
+func TestDuplicateGUIDs(t *testing.T) {
+	eps := fetchFixture(t, "rss", "duplicates.rss")
+	want := []string{"No guid", "Avsnitt 1 (corrected)", "Avsnitt 2"}
+	if got := titles(eps); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := byGUID(eps)["ep-1"].url; got != "https://example.com/1-fixed.mp3" {
+		t.Errorf("kept %s of the duplicates, want the latest", got)
+	}
+}
+
+func TestItunesFields(t *testing.T) {
+	srv := serveFile(t, "itunes.rss")
+	pod := newPod(Config{Name: "Test", URL: srv.URL + "/itunes.rss", Type: "rss"})
+	eps, meta, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMeta := PodMetadata{Title: "Alex & Sigge", Description: "Ett samtal",
+		Link: "https://example.com/", Image: "https://example.com/cover.jpg"}
+	if meta != wantMeta {
+		t.Errorf("got metadata %+v, want %+v", meta, wantMeta)
+	}
+
+	got := byGUID(eps)
+	if ep := got["2"]; ep.author != "Alex" || ep.image != "https://example.com/2.jpg" || ep.episode != 2 || ep.season != 1 {
+		t.Errorf("episode 2: author %q, image %q, episode %d, season %d", ep.author, ep.image, ep.episode, ep.season)
+	}
+	if ep := got["1"]; ep.author != "" || ep.image != "" || ep.episode != 0 || ep.season != 0 {
+		t.Errorf("episode 1: author %q, image %q, episode %d, season %d", ep.author, ep.image, ep.episode, ep.season)
+	}
+
+	resetStore(t)
+	pod.eps = eps
+	store.Add("Test", pod)
+	w := httptest.NewRecorder()
+	index(w, httptest.NewRequest("GET", "/", nil))
+	if n := strings.Count(w.Body.String(), "<small>by Alex</small>"); n != 1 {
+		t.Errorf("the author is on the index %d times, want 1", n)
+	}
+}
+
+func TestCompressedFeeds(t *testing.T) {
+	tests := []struct {
+		encoding string
+		compress func(io.Writer) io.WriteCloser
+	}{
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"x-gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run("encoding "+tt.encoding, func(t *testing.T) {
+			srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != "gzip, deflate" {
+					t.Errorf("Accept-Encoding %q", got)
+				}
+				if tt.compress == nil {
+					fmt.Fprint(w, rssFeed(3))
+					return
+				}
+				w.Header().Set("Content-Encoding", tt.encoding)
+				cw := tt.compress(w)
+				fmt.Fprint(cw, rssFeed(3))
+				cw.Close()
+			})
+			pod := newPod(Config{Name: "Test", URL: srv.URL, Type: "rss"})
+			eps, _, err := pod.fetch(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"Avsnitt 3", "Avsnitt 2", "Avsnitt 1"}
+			if got := titles(eps); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestUnsupportedEncoding(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		fmt.Fprint(w, "not really brotli")
+	})
+	_, _, err := fetchOnce(context.Background(), srv.URL, nil)
+	if err == nil || !strings.Contains(err.Error(), `unsupported content encoding "br"`) {
+		t.Errorf("got %v, want an unsupported encoding error", err)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	// pods of 5 and 2 episodes, titles are the episode numbers
+	pods := func() []TemplatePod {
+		var long, short TemplatePod
+		for i := 1; i <= 5; i++ {
+			long.Episodes = append(long.Episodes, TemplateEpisode{Title: strconv.Itoa(i)})
+		}
+		for i := 1; i <= 2; i++ {
+			short.Episodes = append(short.Episodes, TemplateEpisode{Title: strconv.Itoa(i)})
+		}
+		return []TemplatePod{long, short}
+	}
+	titles := func(p TemplatePod) string {
+		var s []string
+		for _, e := range p.Episodes {
+			s = append(s, e.Title)
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		name               string
+		page, size         int
+		long, short        string
+		wantPage           int
+		wantPrev, wantNext int
+	}{
+		{"first page", 1, 2, "1,2", "1,2", 1, 0, 2},
+		{"middle page", 2, 2, "3,4", "1,2", 2, 1, 3},
+		{"last page", 3, 2, "5", "1,2", 3, 2, 0},
+		{"past the last page", 9, 2, "5", "1,2", 3, 2, 0},
+		{"before the first page", -1, 2, "1,2", "1,2", 1, 0, 2},
+		{"size larger than every pod", 1, 10, "1,2,3,4,5", "1,2", 1, 0, 0},
+		{"no size shows all", 2, 0, "1,2,3,4,5", "1,2", 1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := IndexData{Pods: pods()}
+			paginate(&data, tt.page, tt.size)
+			if got := titles(data.Pods[0]); got != tt.long {
+				t.Errorf("got long pod %q, want %q", got, tt.long)
+			}
+			if got := titles(data.Pods[1]); got != tt.short {
+				t.Errorf("got short pod %q, want %q", got, tt.short)
+			}
+			if data.Page != tt.wantPage || data.PrevPage != tt.wantPrev || data.NextPage != tt.wantNext {
+				t.Errorf("got page %d prev %d next %d, want %d %d %d", data.Page, data.PrevPage,
+					data.NextPage, tt.wantPage, tt.wantPrev, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestRelativeEnclosures(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old.rss" {
+			http.Redirect(w, r, "/moved/relative.rss", http.StatusMovedPermanently)
+			return
+		}
+		http.ServeFile(w, r, "testdata/relative.rss")
+	})
+	tests := []struct {
+		name, feed, dir string
+	}{
+		{"feed url", "/feeds/relative.rss", "/feeds/"},
+		{"in a subdirectory", "/feeds/2020/relative.rss", "/feeds/2020/"},
+		{"after a redirect", "/old.rss", "/moved/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newPod(Config{Name: "Test", URL: srv.URL + tt.feed, Type: "rss"})
+			eps, _, err := pod.fetch(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{
+				"root":     srv.URL + "/episodes/ep42.mp3",
+				"path":     srv.URL + tt.dir + "ep43.mp3",
+				"protocol": "http://cdn.example.com/ep.mp3",
+				"absolute": "https://example.org/abs.mp3?a=1&b=2",
+			}
+			got := byGUID(eps)
+			for guid, u := range want {
+				if got[guid].url != u {
+					t.Errorf("%s: got %q, want %q", guid, got[guid].url, u)
+				}
+			}
+		})
+	}
+}
+
+func TestMediaContent(t *testing.T) {
+	eps := fetchFixture(t, "rss", "media.rss")
+	want := []string{"Enclosure", "Media content", "Both", "Image first"}
+	if got := titles(eps); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q, an item without audio is not an episode", got, want)
+	}
+	tests := []struct {
+		guid, url        string
+		length, duration int64
+	}{
+		{"enclosure", "https://example.com/enclosure.mp3", 1000, 0},
+		{"media", "https://example.com/media.m4a", 2000, 90},
+		{"both", "https://example.com/both-enclosure.mp3", 3000, 0},
+		{"image", "https://example.com/image.ogg", 0, 3600},
+	}
+	byID := byGUID(eps)
+	for _, tt := range tests {
+		ep := byID[tt.guid]
+		if ep.url != tt.url || ep.length != tt.length || ep.duration != tt.duration {
+			t.Errorf("%s: got %q %d bytes %ds, want %q %d bytes %ds", tt.guid,
+				ep.url, ep.length, ep.duration, tt.url, tt.length, tt.duration)
+		}
+	}
+}
+
+func TestMultipleEnclosures(t *testing.T) {
+	eps := byGUID(fetchFixture(t, "rss", "enclosures.rss"))
+	tests := []struct {
+		guid, url, mediaType string
+	}{
+		{"mp3-second", "https://example.com/1.mp3", "audio/mpeg"},
+		{"audio-second", "https://example.com/2.m4a", "audio/mp4"},
+		{"video-only", "https://example.com/3.mp4", "video/mp4"},
+	}
+	for _, tt := range tests {
+		ep := eps[tt.guid]
+		if ep.url != tt.url || ep.mediaType != tt.mediaType {
+			t.Errorf("%s: got %q %q, want %q %q", tt.guid, ep.url, ep.mediaType, tt.url, tt.mediaType)
+		}
+	}
+}
+
+func TestIndexMarksVideo(t *testing.T) {
+	resetStore(t)
+	pod := newPod(Config{Name: "Test", URL: "https://example.com/rss", Type: "rss"})
+	pod.eps = fetchFixture(t, "rss", "enclosures.rss")
+	store.Add("Test", pod)
+
+	w := httptest.NewRecorder()
+	index(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	if n := strings.Count(body, "<small>(video)</small>"); n != 1 {
+		t.Errorf("got %d video marks, want 1 for the video only item", n)
+	}
+	if !strings.Contains(body, `<a href="https://example.com/3.mp4" target="_blank">Video only</a> <small>(video)</small>`) {
+		t.Errorf("the video only item is not marked:\n%s", body)
+	}
+}
+
+func TestConditionalFetch(t *testing.T) {
+	var requests atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == "Wed, 01 Jan 2020 12:00:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2020 12:00:00 GMT")
+		fmt.Fprint(w, rssFeed(2))
+	})
+	pod := newPod(Config{Name: "Test", URL: srv.URL, Type: "rss"})
+	if _, _, err := pod.fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	eps, _, err := pod.fetch(context.Background())
+	if err != errNotModified {
+		t.Fatalf("got %v, want errNotModified", err)
+	}
+	if eps != nil {
+		t.Errorf("a 304 was parsed into %d episodes", len(eps))
+	}
+	if pod.cache.etag != `"v1"` {
+		t.Errorf("a 304 dropped the etag, got %q", pod.cache.etag)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
+
+func TestValidatorsClearedOnNewURL(t *testing.T) {
+	old := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"old"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2020 12:00:00 GMT")
+		fmt.Fprint(w, rssFeed(1))
+	})
+	var conditional atomic.Int32
+	moved := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"new"`)
+		fmt.Fprint(w, rssFeed(3))
+	})
+	pod := newPod(Config{Name: "Test", URL: old.URL, Type: "rss"})
+	if _, _, err := pod.fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pod.moveTo(moved.URL)
+	eps, _, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatalf("fetching the new url: %v", err)
+	}
+	if n := conditional.Load(); n != 0 {
+		t.Errorf("the validators of the old url were sent to the new one")
+	}
+	if len(eps) != 3 {
+		t.Errorf("got %d episodes from the new url, want 3", len(eps))
+	}
+	if pod.cache.url != moved.URL || pod.cache.etag != `"new"` || pod.cache.lastModified != "" {
+		t.Errorf("got validators %+v, want the ones of the new url", pod.cache)
+	}
+}
+
+// setMaxFeedSize sets -max-feed-size for the test
+func setMaxFeedSize(t *testing.T, n int64) {
+	t.Helper()
+	old := *maxFeedSize
+	*maxFeedSize = n
+	t.Cleanup(func() { *maxFeedSize = old })
+}
+
+func TestFeedTooLarge(t *testing.T) {
+	setMaxFeedSize(t, 1024)
+	setRetries(t, 1)
+	feed := rssFeed(20)
+	tests := []struct {
+		name  string
+		serve http.HandlerFunc
+	}{
+		{"content length", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, feed)
+		}},
+		{"chunked", func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < len(feed); i += 100 {
+				fmt.Fprint(w, feed[i:min(i+100, len(feed))])
+				w.(http.Flusher).Flush()
+			}
+		}},
+		{"gzipped", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			gw := gzip.NewWriter(w)
+			fmt.Fprint(gw, feed+strings.Repeat(" ", 1<<20))
+			gw.Close()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serve(t, tt.serve)
+			want := fmt.Sprintf("fetching %s: feed too large, more than 1024 bytes", srv.URL)
+			for _, kind := range []string{"rss", "feed"} {
+				resetStore(t)
+				pod := newPod(Config{Name: "Test", URL: srv.URL, Type: kind})
+				store.Add("Test", pod)
+				store.Update(context.Background(), true)
+				if pod.lastError == nil || pod.lastError.Error() != want {
+					t.Errorf("%s: got %v, want %s", kind, pod.lastError, want)
+				}
+			}
+
+			_, err := NewPodFromURL(srv.URL)
+			if err == nil || err.Error() != want {
+				t.Errorf("subscribing: got %v, want %s", err, want)
+			}
+		})
+	}
+}
+
+func BenchmarkParseRss(b *testing.B) {
+	feed := rssFeed(20000)
+	b.SetBytes(int64(len(feed)))
+	b.ReportAllocs()
+	for b.Loop() {
+		eps, _, err := parseRss(strings.NewReader(feed), 10)
+		if err != nil || len(eps) != 10 {
+			b.Fatalf("got %d episodes, %v", len(eps), err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// mergedFeed builds an rss feed with the newest episode of every pod
+func mergedFeed() RssFeed {
+	feed := RssFeed{Version: "2.0", Channel: RssChannel{Title: "Pods"}}
+
+	var eps []Episode
+	store.RLock()
+	for _, pod := range store.pods {
+		if len(pod.eps) == 0 {
+			continue
+		}
+		ep := pod.eps[0]
+		ep.name = fmt.Sprintf("%s: %s", pod.name, ep.name)
+		eps = append(eps, ep)
+	}
+	store.RUnlock()
+
+	sort.Stable(byEpisodeDate(eps))
+	for _, ep := range eps {
+		item := RssItem{
+			Title:       ep.name,
+			GUID:        ep.guid,
+			Enclosures:  []RssEnclosure{{URL: ep.url, Length: rssLength(ep.length), Type: ep.mediaType}},
+			Subtitle:    ep.subtitle,
+			PubDate:     RssTime{ep.pubDate},
+			Description: ep.description,
+			ItunesItem: ItunesItem{Duration: rssDuration(ep.duration),
+				Author:  ep.author,
+				Episode: rssNumber(ep.episode),
+				Season:  rssNumber(ep.season)},
+		}
+		if ep.image != "" {
+			item.Image = &ItunesImage{Href: ep.image}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
+
+// allRss serves GET /all.rss, a feed of the latest episode of every pod
+func allRss(w http.ResponseWriter, r *http.Request) {
+	bs, err := xml.MarshalIndent(mergedFeed(), "", "  ")
+	if err != nil {
+		slog.Error("encoding all.rss", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(bs)
+}
+
+// rssDuration formats seconds as an itunes:duration, 0 is left out
+func rssDuration(secs int64) string {
+	if secs <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs%3600/60, secs%60)
+}
+
+// rssLength formats an enclosure length, 0 is left out
+func rssLength(bytes int64) string {
+	if bytes <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(bytes, 10)
+}
+
+// rssNumber formats an itunes:episode or itunes:season, 0 is left out
+func rssNumber(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAllRss(t *testing.T) {
+	resetStore(t)
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	old := newPod(Config{Name: "Old", URL: "https://example.com/old", Type: "rss"})
+	old.eps = []Episode{{name: "Old 2", url: "https://example.com/o2.mp3", pubDate: day(2)},
+		{name: "Old 1", url: "https://example.com/o1.mp3", pubDate: day(1)}}
+	store.Add("Old", old)
+	recent := newPod(Config{Name: "Recent", URL: "https://example.com/recent", Type: "rss"})
+	recent.eps = []Episode{{name: "Recent & new", url: "https://example.com/r1.mp3", pubDate: day(5),
+		description: "<p>a <b>bold</b> description</p>"}}
+	store.Add("Recent", recent)
+	store.Add("Empty", newPod(Config{Name: "Empty", URL: "https://example.com/empty", Type: "rss"}))
+
+	w := httptest.NewRecorder()
+	allRss(w, httptest.NewRequest("GET", "/all.rss", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/rss+xml; charset=utf-8" {
+		t.Errorf("got content type %q", ct)
+	}
+	var feed RssFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid xml: %s\n%s", err, w.Body)
+	}
+	var got []string
+	for _, item := range feed.Channel.Items {
+		got = append(got, item.Title)
+	}
+	want := []string{"Recent: Recent & new", "Old: Old 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if d := feed.Channel.Items[0].Description; d != "<p>a <b>bold</b> description</p>" {
+		t.Errorf("got description %q", d)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "strings"
+
+// PodMetadata describes the podcast itself rather than its episodes
+type PodMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Link        string `json:"link,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// firstNonEmpty returns the first of ss that isn't blank, trimmed
+func firstNonEmpty(ss ...string) string {
+	for _, s := range ss {
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+	return ""
+}
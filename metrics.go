@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are the prometheus metrics about feed updates
+type Metrics struct {
+	updateDuration *prometheus.HistogramVec
+	episodeCount   *prometheus.GaugeVec
+	updateErrors   *prometheus.CounterVec
+	lastUpdate     prometheus.Gauge
+}
+
+// NewMetrics creates the metrics and registers them with the default registry
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		updateDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pods_update_duration_seconds",
+			Help:    "Time taken to fetch and parse a feed.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+		}, []string{"podcast"}),
+		episodeCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pods_episode_count",
+			Help: "Number of episodes kept for a podcast.",
+		}, []string{"podcast"}),
+		updateErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pods_update_errors_total",
+			Help: "Number of failed feed updates.",
+		}, []string{"podcast", "type"}),
+		lastUpdate: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pods_last_update_timestamp",
+			Help: "Unix time of the last completed podcast update.",
+		}),
+	}
+	prometheus.MustRegister(m.updateDuration, m.episodeCount, m.updateErrors, m.lastUpdate)
+	return m
+}
+
+var metrics = NewMetrics()
+
+// observe records one update of the podcast name
+func (m *Metrics) observe(name string, d time.Duration, episodes int, err error) {
+	m.updateDuration.WithLabelValues(name).Observe(d.Seconds())
+	m.episodeCount.WithLabelValues(name).Set(float64(episodes))
+	if err != nil && err != errNotModified {
+		m.updateErrors.WithLabelValues(name, errorType(err)).Inc()
+	}
+}
+
+// forget drops the metrics of a removed podcast
+func (m *Metrics) forget(name string) {
+	m.updateDuration.DeleteLabelValues(name)
+	m.episodeCount.DeleteLabelValues(name)
+	m.updateErrors.DeletePartialMatch(prometheus.Labels{"podcast": name})
+}
+
+// errorType classifies update errors for the errors metric
+func errorType(err error) string {
+	var se *xml.SyntaxError
+	var tl tooLargeError
+	switch {
+	case err == errNoEpisodes:
+		return "empty"
+	case isPartial(err):
+		return "skipped"
+	case isTimeout(err):
+		return "timeout"
+	case errors.As(err, &se):
+		return "parse"
+	case errors.As(err, &tl):
+		return "too_large"
+	}
+	return "fetch"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricValue returns the value of the sample matching the name and labels
+// in series, as scraped from /metrics
+func metricValue(t *testing.T, series string) float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	m := regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(series) + ` (\S+)$`).FindStringSubmatch(w.Body.String())
+	if m == nil {
+		t.Fatalf("no %s in\n%s", series, w.Body)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMetricsAfterUpdate(t *testing.T) {
+	resetStore(t)
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			fmt.Fprint(w, rssFeed(0))
+			return
+		}
+		fmt.Fprint(w, rssFeed(3))
+	})
+	store.Add("metrics ok", newPod(Config{Name: "metrics ok", URL: srv.URL + "/ok", Type: "rss"}))
+	store.Add("metrics empty", newPod(Config{Name: "metrics empty", URL: srv.URL + "/empty", Type: "rss"}))
+	store.Update(context.Background(), true)
+
+	if v := metricValue(t, `pods_episode_count{podcast="metrics ok"}`); v != 3 {
+		t.Errorf("episode count %v, want 3", v)
+	}
+	if v := metricValue(t, `pods_update_duration_seconds_count{podcast="metrics ok"}`); v != 1 {
+		t.Errorf("%v updates observed, want 1", v)
+	}
+	if v := metricValue(t, `pods_update_errors_total{podcast="metrics empty",type="empty"}`); v != 1 {
+		t.Errorf("%v errors, want 1", v)
+	}
+	if v := metricValue(t, `pods_last_update_timestamp`); v == 0 {
+		t.Error("last update timestamp is not set")
+	}
+
+	store.Remove("metrics ok")
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if regexp.MustCompile(`podcast="metrics ok"`).Match(w.Body.Bytes()) {
+		t.Error("metrics of a removed podcast are still exported")
+	}
+}
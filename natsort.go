@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// collator compares the text between the numbers of titles in Swedish
+// order, z < å < ä < ö. A Collator is not safe for concurrent use so it is
+// only used with collatorMu held.
+var (
+	collatorMu sync.Mutex
+	collator   = collate.New(language.Swedish, collate.IgnoreCase)
+)
+
+// compareText compares a and b with collator
+func compareText(a, b string) int {
+	collatorMu.Lock()
+	defer collatorMu.Unlock()
+	return collator.CompareString(a, b)
+}
+
+// naturalLess compares titles so that runs of digits are compared by their
+// numeric value, "Avsnitt 99" < "Avsnitt 100" and "S02E9" < "S02E10". Text
+// is compared case-insensitively in Swedish order.
+func naturalLess(a, b string) bool {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+	for len(ra) > 0 && len(rb) > 0 {
+		da, db := unicode.IsDigit(ra[0]), unicode.IsDigit(rb[0])
+		if da != db {
+			// digits sort before text
+			return da
+		}
+		var ca, cb []rune
+		ca, ra = splitRun(ra, da)
+		cb, rb = splitRun(rb, db)
+		if da {
+			if c := compareNumbers(ca, cb); c != 0 {
+				return c < 0
+			}
+			continue
+		}
+		if c := compareText(string(ca), string(cb)); c != 0 {
+			return c < 0
+		}
+	}
+	return len(ra) < len(rb)
+}
+
+// splitRun returns the leading run of digits (or non-digits) in rs and the rest
+func splitRun(rs []rune, digits bool) ([]rune, []rune) {
+	i := 0
+	for i < len(rs) && unicode.IsDigit(rs[i]) == digits {
+		i++
+	}
+	return rs[:i], rs[i:]
+}
+
+// compareNumbers compares two runs of digits by value, equal values with
+// more leading zeros sort after those with fewer
+func compareNumbers(a, b []rune) int {
+	ta := trimZeros(a)
+	tb := trimZeros(b)
+	if len(ta) != len(tb) {
+		if len(ta) < len(tb) {
+			return -1
+		}
+		return 1
+	}
+	for i := range ta {
+		if ta[i] != tb[i] {
+			if ta[i] < tb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func trimZeros(rs []rune) []rune {
+	for len(rs) > 1 && rs[0] == '0' {
+		rs = rs[1:]
+	}
+	return rs
+}
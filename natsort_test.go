@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Avsnitt 99", "Avsnitt 100", true},
+		{"Avsnitt 100", "Avsnitt 99", false},
+		{"S02E9", "S02E10", true},
+		{"S2E10", "S10E2", true},
+		{"avsnitt 1", "Avsnitt 2", true},
+		{"Avsnitt 1", "avsnitt 1", false},
+		{"007", "7", false},
+		{"7", "007", true},
+		{"1 Intro", "Intro", true},
+		{"Avsnitt", "Avsnitt 1", true},
+		{"Zebra", "Ål", true},
+		{"Ål", "Ärlig", true},
+		{"Ärlig", "Öga", true},
+		{"Öga", "Zebra", false},
+		{"ål", "Ä", true},
+		{"Vår 2", "Vår 10", true},
+	}
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNaturalSort(t *testing.T) {
+	titles := []string{"Öga", "Avsnitt 10", "Ärlig", "Zebra", "Avsnitt 9", "Ål", "avsnitt 2"}
+	want := []string{"avsnitt 2", "Avsnitt 9", "Avsnitt 10", "Zebra", "Ål", "Ärlig", "Öga"}
+	sort.Slice(titles, func(i, j int) bool { return naturalLess(titles[i], titles[j]) })
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Fatalf("got %q, want %q", titles, want)
+		}
+	}
+}
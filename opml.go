@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// OPML is the root of an opml document
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    OPMLHead `xml:"head"`
+	Body    OPMLBody `xml:"body"`
+}
+
+// OPMLHead is the metadata of the document
+type OPMLHead struct {
+	Title string `xml:"title"`
+}
+
+// OPMLBody holds the outlines
+type OPMLBody struct {
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// OPMLOutline is a feed if XMLURL is set, otherwise a group of outlines
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// ImportOPML reads a subscription list and returns a Pod for every feed in
+// it, the format of each feed is detected when it is fetched. Outlines of
+// another type than rss, like links, and feeds that do not validate are
+// left out.
+func ImportOPML(r io.Reader) ([]*Pod, error) {
+	var doc OPML
+	err := newDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*Pod
+	var walk func(outlines []OPMLOutline)
+	walk = func(outlines []OPMLOutline) {
+		for _, o := range outlines {
+			walk(o.Outlines)
+			if o.XMLURL == "" || (o.Type != "" && !strings.EqualFold(o.Type, "rss")) {
+				continue
+			}
+			name := o.Text
+			if name == "" {
+				name = o.Title
+			}
+			if name == "" {
+				name = o.XMLURL
+			}
+			c := Config{Name: name, URL: o.XMLURL, Type: "feed"}
+			if err := c.validate(); err != nil {
+				slog.Warn("skipping opml outline", "err", err)
+				continue
+			}
+			res = append(res, newPod(c))
+		}
+	}
+	walk(doc.Body.Outlines)
+	return res, nil
+}
+
+// ExportOPML writes pods as an opml 2.0 subscription list
+func ExportOPML(pods map[string]*Pod) ([]byte, error) {
+	doc := OPML{Version: "2.0", Head: OPMLHead{Title: "Pods"}}
+	for _, pod := range pods {
+		doc.Body.Outlines = append(doc.Body.Outlines, OPMLOutline{
+			Text:   pod.name,
+			Type:   "rss",
+			XMLURL: pod.url,
+		})
+	}
+	sort.Slice(doc.Body.Outlines, func(i, j int) bool {
+		return doc.Body.Outlines[i].Text < doc.Body.Outlines[j].Text
+	})
+
+	bs, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), bs...), nil
+}
+
+// exportOPML handles GET /export.opml
+func exportOPML(w http.ResponseWriter, r *http.Request) {
+	bs, err := ExportOPML(store.All())
+	if err != nil {
+		slog.Error("exporting opml", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="pods.opml"`)
+	w.Write(bs)
+}
+
+// importOPML handles POST /import-opml with the opml file in the form field "file"
+func importOPML(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(w, r) {
+		return
+	}
+
+	f, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	imported, err := ImportOPML(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var added []*Pod
+	store.Lock()
+	urls := make(map[string]bool)
+	for _, pod := range store.pods {
+		urls[pod.url] = true
+	}
+	for _, pod := range imported {
+		key := strings.ToLower(pod.name)
+		if _, ok := store.pods[key]; ok || urls[pod.url] {
+			continue
+		}
+		pod.added = true
+		store.pods[key] = pod
+		urls[pod.url] = true
+		added = append(added, pod)
+	}
+	store.Unlock()
+
+	slog.Info("imported opml", "added", len(added), "total", len(imported))
+	if len(added) > 0 {
+		go store.UpdatePods(shutdown, added)
+	}
+	fmt.Fprintf(w, "Imported %d of %d podcasts\n", len(added), len(imported))
+}
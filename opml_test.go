@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// opmlFeeds returns the name and url of every pod
+func opmlFeeds(pods []*Pod) map[string]string {
+	m := make(map[string]string, len(pods))
+	for _, pod := range pods {
+		m[pod.name] = pod.url
+	}
+	return m
+}
+
+func TestOPMLRoundTrip(t *testing.T) {
+	f, err := os.Open("testdata/subscriptions.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	imported, err := ImportOPML(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Kodsnack":                         "https://kodsnack.se/feed/",
+		"Alex & Sigge":                     "https://rss.acast.com/alexosigge",
+		"Go Time":                          "https://changelog.com/gotime/feed",
+		"https://example.com/untitled.xml": "https://example.com/untitled.xml",
+	}
+	if got := opmlFeeds(imported); !reflect.DeepEqual(got, want) {
+		t.Fatalf("imported %v, want %v", got, want)
+	}
+
+	pods := make(map[string]*Pod)
+	for _, pod := range imported {
+		pods[pod.name] = pod
+	}
+	bs, err := ExportOPML(pods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ImportOPML(bytes.NewReader(bs))
+	if err != nil {
+		t.Fatalf("importing the export: %s\n%s", err, bs)
+	}
+	if got := opmlFeeds(again); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip gave %v, want %v", got, want)
+	}
+}
+
+func TestImportOPMLInvalid(t *testing.T) {
+	if _, err := ImportOPML(bytes.NewReader([]byte("<opml><body><outline"))); err == nil {
+		t.Error("truncated opml was accepted")
+	}
+}
+
+// opmlUpload returns a POST /import-opml request with doc as the file
+func opmlUpload(t *testing.T, doc string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "pods.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(fw, doc)
+	mw.Close()
+	r := httptest.NewRequest("POST", "/import-opml", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestImportOPMLUpdatesThroughStore(t *testing.T) {
+	resetStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	old := shutdown
+	shutdown = ctx
+	t.Cleanup(func() {
+		cancel()
+		shutdown = old
+	})
+	var fetches atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		fmt.Fprint(w, rssFeed(1))
+	})
+	// the update started by the import saves the state after the episodes
+	// are in, it must be done before the next test
+	t.Cleanup(func() {
+		store.updating.Lock()
+		store.updating.Unlock()
+	})
+
+	// an update in progress holds off the import
+	store.updating.Lock()
+	w := httptest.NewRecorder()
+	importOPML(w, opmlUpload(t, `<opml version="2.0"><body><outline text="Test" xmlUrl="`+srv.URL+`" /></body></opml>`))
+	if !strings.HasPrefix(w.Body.String(), "Imported 1 of 1") {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := fetches.Load(); n != 0 {
+		t.Errorf("imported pod was fetched during an update")
+	}
+	store.updating.Unlock()
+
+	pod, ok := store.Get("Test")
+	if !ok {
+		t.Fatal("imported pod is missing")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		store.RLock()
+		n := len(pod.eps)
+		store.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("imported pod was not updated, %d fetches", fetches.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !pod.added {
+		t.Error("imported pod is not marked as added")
+	}
+}
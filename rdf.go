@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strings"
+)
+
+// RdfFeed is the root of an RSS 1.0 feed, its items are siblings of the
+// channel rather than children
+type RdfFeed struct {
+	XMLName xml.Name   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# RDF"`
+	Channel RdfChannel `xml:"http://purl.org/rss/1.0/ channel"`
+	Image   RssImage   `xml:"http://purl.org/rss/1.0/ image"`
+	Items   []RdfItem  `xml:"http://purl.org/rss/1.0/ item"`
+}
+
+// RdfChannel describes the feed
+type RdfChannel struct {
+	Title       string `xml:"http://purl.org/rss/1.0/ title"`
+	Description string `xml:"http://purl.org/rss/1.0/ description"`
+	Link        string `xml:"http://purl.org/rss/1.0/ link"`
+}
+
+// RdfItem represents an individual item in the feed
+type RdfItem struct {
+	Title       string       `xml:"http://purl.org/rss/1.0/ title"`
+	Description string       `xml:"http://purl.org/rss/1.0/ description"`
+	Date        RssTime      `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Enclosure   RdfEnclosure `xml:"http://purl.oclc.org/net/rss_2.0/enc# enclosure"`
+}
+
+// RdfEnclosure is the media of an item, from the mod_enclosure module
+type RdfEnclosure struct {
+	Resource string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# resource,attr"`
+	Length   string `xml:"http://purl.oclc.org/net/rss_2.0/enc# length,attr"`
+	Type     string `xml:"http://purl.oclc.org/net/rss_2.0/enc# type,attr"`
+}
+
+// RdfParser implements the parser interface and the string is the url for the feed
+type RdfParser string
+
+// URLs extracts at most limit media-links from rdf, limit 0 means all of them
+func (rp RdfParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	bs, res, err := fetchContent(ctx, string(rp), v.header(string(rp)))
+	if err != nil {
+		return res, err
+	}
+	res.eps, res.meta, err = parseRdf(bs, limit)
+	return res, err
+}
+
+// parseRdf extracts at most limit episodes and the channel metadata from the
+// rss 1.0 document in bs
+func parseRdf(bs []byte, limit int) ([]Episode, PodMetadata, error) {
+	rdf := RdfFeed{}
+	err := newDecoder(bytes.NewReader(bs)).Decode(&rdf)
+	if err != nil {
+		return nil, PodMetadata{}, err
+	}
+
+	eps := make([]Episode, 0, len(rdf.Items))
+	for _, it := range rdf.Items {
+		if it.Enclosure.Resource == "" {
+			continue
+		}
+		eps = append(eps, Episode{name: it.Title,
+			url:         it.Enclosure.Resource,
+			pubDate:     it.Date.Time,
+			description: strings.TrimSpace(it.Description),
+			length:      parseLength(it.Enclosure.Length),
+			mediaType:   it.Enclosure.Type})
+	}
+	meta := PodMetadata{Title: strings.TrimSpace(rdf.Channel.Title),
+		Description: strings.TrimSpace(rdf.Channel.Description),
+		Link:        strings.TrimSpace(rdf.Channel.Link),
+		Image:       strings.TrimSpace(rdf.Image.URL)}
+	return newest(eps, limit), meta, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxRedirects is how many redirects a fetch follows before giving up
+const maxRedirects = 10
+
+// checkRedirect stops redirect loops and chains longer than maxRedirects
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			return fmt.Errorf("redirect loop at %s", req.URL)
+		}
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("more than %d redirects from %s", maxRedirects, via[0].URL)
+	}
+	return nil
+}
+
+// movedURL returns where res was finally fetched from when every redirect
+// on the way was permanent, and "" when there were none or any of them was
+// temporary
+func movedURL(res *http.Response) string {
+	if res.Request == nil || res.Request.Response == nil {
+		return ""
+	}
+	for r := res.Request; r.Response != nil; r = r.Response.Request {
+		switch r.Response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		default:
+			return ""
+		}
+	}
+	return res.Request.URL.String()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// hop is a redirect with status code to the path to
+type hop struct {
+	code int
+	to   string
+}
+
+// redirects starts a test server answering the paths in hops with their
+// redirect and any other path with a feed, it returns the server url
+func redirects(t *testing.T, hops map[string]hop) string {
+	t.Helper()
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		if hop, ok := hops[r.URL.Path]; ok {
+			http.Redirect(w, r, hop.to, hop.code)
+			return
+		}
+		fmt.Fprint(w, rssFeed(1))
+	})
+	return srv.URL
+}
+
+func TestRedirects(t *testing.T) {
+	tests := []struct {
+		name  string
+		hops  map[string]hop
+		moved bool
+	}{
+		{"301", map[string]hop{"/old": {http.StatusMovedPermanently, "/feed.rss"}}, true},
+		{"301 then 308", map[string]hop{
+			"/old":    {http.StatusMovedPermanently, "/middle"},
+			"/middle": {http.StatusPermanentRedirect, "/feed.rss"}}, true},
+		{"302", map[string]hop{"/old": {http.StatusFound, "/feed.rss"}}, false},
+		{"307", map[string]hop{"/old": {http.StatusTemporaryRedirect, "/feed.rss"}}, false},
+		{"301 then 302", map[string]hop{
+			"/old":    {http.StatusMovedPermanently, "/middle"},
+			"/middle": {http.StatusFound, "/feed.rss"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStore(t)
+			mem := NewMemStore()
+			setStateStore(t, mem)
+			base := redirects(t, tt.hops)
+			pod := newPod(Config{Name: "Test", URL: base + "/old", Type: "rss"})
+			store.Add("Test", pod)
+			store.Update(context.Background(), true)
+
+			want := base + "/old"
+			if tt.moved {
+				want = base + "/feed.rss"
+			}
+			if pod.url != want {
+				t.Errorf("url %s, want %s", pod.url, want)
+			}
+			if len(pod.eps) != 1 || pod.lastError != nil {
+				t.Errorf("got %d episodes and error %v", len(pod.eps), pod.lastError)
+			}
+			pods, err := mem.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pods) != 1 || pods[0].URL != want {
+				t.Fatalf("saved %+v, want the url %s", pods, want)
+			}
+			if tt.moved && pods[0].ConfigURL != base+"/old" {
+				t.Errorf("saved config url %q, want the configured url", pods[0].ConfigURL)
+			}
+		})
+	}
+}
+
+func TestRedirectLimits(t *testing.T) {
+	endless := make(map[string]hop)
+	for i := range 2 * maxRedirects {
+		endless["/"+strconv.Itoa(i)] = hop{http.StatusMovedPermanently, "/" + strconv.Itoa(i+1)}
+	}
+	tests := []struct {
+		name, start string
+		hops        map[string]hop
+		want        string
+	}{
+		{"loop", "/a", map[string]hop{
+			"/a": {http.StatusMovedPermanently, "/b"},
+			"/b": {http.StatusMovedPermanently, "/a"}}, "redirect loop at "},
+		{"too many", "/0", endless, fmt.Sprintf("more than %d redirects from ", maxRedirects)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := redirects(t, tt.hops)
+			pod := newPod(Config{Name: "Test", URL: base + tt.start, Type: "rss"})
+			_, _, err := pod.fetch(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error with %q", err, tt.want)
+			}
+			if pod.url != base+tt.start {
+				t.Errorf("url changed to %s", pod.url)
+			}
+		})
+	}
+}
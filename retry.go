@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// retryBase is the delay before the first retry, it doubles for every attempt
+const retryBase = time.Second
+
+// fetch downloads the body of url, retrying up to -retries attempts in total
+// when the server answers 5xx, times out or refuses or resets the connection
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	bs, _, err := fetchContent(ctx, url, nil)
+	return bs, err
+}
+
+// fetchContent is fetch that sends header with the request and also returns
+// what the response said about the feed. Bodies fetched less than
+// -cache-ttl ago are served from fetchCache.
+func fetchContent(ctx context.Context, url string, header http.Header) ([]byte, fetchResult, error) {
+	if bs, contentType, ok := fetchCache.Get(url); ok {
+		slog.Debug("using cached feed", "url", url)
+		return bs, fetchResult{contentType: contentType}, nil
+	}
+	var bs []byte
+	var res fetchResult
+	err := withRetries(ctx, url, func() (err error) {
+		bs, res, err = fetchOnce(ctx, url, header)
+		return err
+	})
+	if err != nil {
+		return nil, res, err
+	}
+	fetchCache.Put(url, bs, res.contentType)
+	return bs, res, nil
+}
+
+// fetchStream is fetchContent for a body that is decoded as it is read
+// instead of being read whole first. Only opening the body is retried, and
+// it is kept in fetchCache once it is read to the end if it is small enough.
+func fetchStream(ctx context.Context, url string, header http.Header) (io.ReadCloser, fetchResult, error) {
+	if bs, contentType, ok := fetchCache.Get(url); ok {
+		slog.Debug("using cached feed", "url", url)
+		return io.NopCloser(bytes.NewReader(bs)), fetchResult{contentType: contentType}, nil
+	}
+	var body io.ReadCloser
+	var res fetchResult
+	err := withRetries(ctx, url, func() (err error) {
+		body, res, err = fetchBody(ctx, url, header)
+		return err
+	})
+	if err != nil {
+		return nil, res, err
+	}
+	return fetchCache.Tee(url, body, res.contentType), res, nil
+}
+
+// withRetries calls f for url until it succeeds, up to -retries attempts
+// in total while its error is retryable
+func withRetries(ctx context.Context, url string, f func() error) error {
+	var err error
+	for attempt := 0; attempt < *retries; attempt++ {
+		if attempt > 0 {
+			d := retryDelay(attempt)
+			slog.Debug("retrying fetch", "url", url, "delay", d.Round(time.Millisecond), "err", err)
+			t := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return err
+			case <-t.C:
+			}
+		}
+		err = f()
+		if err == nil || !retryable(ctx, err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryDelay is the exponential backoff before the given attempt, with up
+// to half of it random so many feeds on one host don't retry in lockstep
+func retryDelay(attempt int) time.Duration {
+	d := retryBase << (attempt - 1)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryable reports whether err is worth another attempt: server errors,
+// timeouts and refused or reset connections. Not client errors, a server
+// asking us to come back later, a cancelled ctx or network errors that
+// will fail the same way again, like a host that does not resolve.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 && se.retryAfter.IsZero()
+	}
+	return isTimeout(err) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Time
+	}{
+		{"120", now.Add(2 * time.Minute)},
+		{" 0 ", now},
+		{"Wed, 01 Jan 2020 13:30:00 GMT", time.Date(2020, 1, 1, 13, 30, 0, 0, time.UTC)},
+		{"Wednesday, 01-Jan-20 13:30:00 GMT", time.Date(2020, 1, 1, 13, 30, 0, 0, time.UTC)},
+		{"", time.Time{}},
+		{"-5", time.Time{}},
+		{"soon", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); !got.Equal(tt.want) {
+			t.Errorf("%q: got %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	setRetries(t, 1)
+	until := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name, retryAfter string
+		code             int
+		want             time.Time
+	}{
+		{"429 with seconds", "3600", http.StatusTooManyRequests, time.Now().Add(time.Hour)},
+		{"503 with a date", until.Format(http.TimeFormat), http.StatusServiceUnavailable, until},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStore(t)
+			var n atomic.Int32
+			srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+				n.Add(1)
+				w.Header().Set("Retry-After", tt.retryAfter)
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(tt.code)
+				fmt.Fprint(w, "<html><body>slow down</body></html>")
+			})
+			pod := newPod(Config{Name: "Test", URL: srv.URL, Type: "rss"})
+			store.Add("Test", pod)
+			store.Update(context.Background(), true)
+
+			if d := pod.rateLimited.Sub(tt.want); d < -time.Minute || d > time.Minute {
+				t.Errorf("rate limited until %s, want %s", pod.rateLimited, tt.want)
+			}
+			var se *statusError
+			if !errors.As(pod.lastError, &se) || se.code != tt.code {
+				t.Errorf("got error %v, want a %d status error", pod.lastError, tt.code)
+			}
+			if pod.failures != 0 {
+				t.Errorf("being rate limited counted as %d failures", pod.failures)
+			}
+
+			store.Update(context.Background(), true)
+			if got := n.Load(); got != 1 {
+				t.Errorf("%d requests, want none while rate limited", got-1)
+			}
+			w := httptest.NewRecorder()
+			index(w, httptest.NewRequest("GET", "/", nil))
+			want := "Rate limited until " + pod.rateLimited.Format("2006-01-02 15:04")
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("index is missing %q", want)
+			}
+		})
+	}
+}
+func TestRetryable(t *testing.T) {
+	dial := func(errno syscall.Errno) error {
+		return &url.Error{Op: "Get", URL: "http://example.com/",
+			Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"500", &statusError{code: 500}, true},
+		{"502", &statusError{code: 502}, true},
+		{"503 with Retry-After", &statusError{code: 503, retryAfter: time.Now().Add(time.Minute)}, false},
+		{"404", &statusError{code: 404}, false},
+		{"429", &statusError{code: 429, retryAfter: time.Now().Add(time.Minute)}, false},
+		{"timeout", timeoutError("http://example.com/"), true},
+		{"connection refused", dial(syscall.ECONNREFUSED), true},
+		{"connection reset", dial(syscall.ECONNRESET), true},
+		{"unknown host", &url.Error{Op: "Get", URL: "http://nowhere.invalid/",
+			Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Name: "nowhere.invalid", IsNotFound: true}}}, false},
+		{"unreachable network", dial(syscall.ENETUNREACH), false},
+		{"parse error", fmt.Errorf("XML syntax error"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(context.Background(), tt.err); got != tt.want {
+			t.Errorf("%s: retryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if retryable(ctx, &statusError{code: 500}) {
+		t.Error("retrying with a cancelled context")
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	setRetries(t, 2)
+	tests := []struct {
+		name     string
+		codes    []int
+		attempts int32
+		ok       bool
+	}{
+		{"server error then ok", []int{500, 200}, 2, true},
+		{"not found", []int{404}, 1, false},
+		{"server errors", []int{500, 500, 200}, 2, false},
+	}
+	for _, tt := range tests {
+		var n atomic.Int32
+		srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			code := tt.codes[n.Add(1)-1]
+			w.WriteHeader(code)
+			fmt.Fprint(w, rssFeed(1))
+		})
+		_, err := fetch(context.Background(), srv.URL)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+		if got := n.Load(); got != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, got, tt.attempts)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// descriptionPolicy keeps the formatting and links of show notes and drops
+// everything else, including scripts, iframes and styles
+var descriptionPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "b", "strong", "i", "em", "ul", "ol", "li")
+	p.AllowStandardURLs()
+	p.AllowAttrs("href").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// sanitizeDescription makes the html of a feed description safe to put in
+// the index page
+func sanitizeDescription(s string) template.HTML {
+	return template.HTML(descriptionPolicy.Sanitize(s))
+}
+
+// textPolicy drops all html
+var textPolicy = bluemonday.StrictPolicy()
+
+// plainText strips all html from s, for text that is shown on one line
+func plainText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(textPolicy.Sanitize(s)))
+}
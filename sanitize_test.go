@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeCDATADescription(t *testing.T) {
+	eps := fetchFixture(t, "rss", "shownotes.rss")
+	if len(eps) != 1 {
+		t.Fatalf("got %d episodes, want 1", len(eps))
+	}
+	got := string(sanitizeDescription(eps[0].description))
+	for _, bad := range []string{"<script", "alert(", "onclick", "<iframe", "<style", "display: none", "javascript:", "style="} {
+		if strings.Contains(got, bad) {
+			t.Errorf("%q was not stripped from %s", bad, got)
+		}
+	}
+	for _, want := range []string{
+		"<p>Vi pratar om <b>Go</b>.</p>",
+		`<a href="https://go.dev/" rel="nofollow noopener" target="_blank">go.dev</a>`,
+		"<li>klicka</li>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%s is missing from %s", want, got)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"  Alex &amp; Sigge ", "Alex & Sigge"},
+		{"<p>Om <b>Go</b></p><script>alert(1)</script>", "Om Go"},
+	}
+	for _, tt := range tests {
+		if got := plainText(tt.in); got != tt.want {
+			t.Errorf("plainText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// ScrapedParser implements the parser interface for web pages that link to
+// their episodes without publishing a feed, the links are found with css
+// selectors
+type ScrapedParser struct {
+	url string
+	// URLSelector matches the links to the media, the url is their href or
+	// src attribute
+	URLSelector string
+	// TitleSelector matches the titles of the episodes, the n:th title
+	// belongs to the n:th link. Without it the text of a link is its title.
+	TitleSelector string
+	// BaseURL resolves relative links, the url of the page when empty
+	BaseURL string
+}
+
+// validateScrape checks the settings of the scrape type
+func (c Config) validateScrape() error {
+	if c.URLSelector == "" {
+		return fmt.Errorf("%s: missing url_selector", c.Name)
+	}
+	if _, err := cascadia.Compile(c.URLSelector); err != nil {
+		return fmt.Errorf("%s: url_selector: %s", c.Name, err.Error())
+	}
+	if c.TitleSelector != "" {
+		if _, err := cascadia.Compile(c.TitleSelector); err != nil {
+			return fmt.Errorf("%s: title_selector: %s", c.Name, err.Error())
+		}
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || !u.IsAbs() {
+			return fmt.Errorf("%s: base_url %q is not absolute", c.Name, c.BaseURL)
+		}
+	}
+	return nil
+}
+
+// URLs extracts at most limit media-links from the page
+func (sp *ScrapedParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	bs, res, err := fetchContent(ctx, sp.url, v.header(sp.url))
+	if err != nil {
+		return res, err
+	}
+	res.eps, res.meta, err = sp.parse(bs, limit)
+	return res, err
+}
+
+// parse extracts at most limit episodes and the metadata from the html page
+// in bs. The page has no dates, so newest sorts the episodes by title with
+// the highest episode number first, not in the order of the page.
+func (sp *ScrapedParser) parse(bs []byte, limit int) ([]Episode, PodMetadata, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bs))
+	if err != nil {
+		return nil, PodMetadata{}, err
+	}
+	base, err := url.Parse(firstNonEmpty(sp.BaseURL, sp.url))
+	if err != nil {
+		return nil, PodMetadata{}, err
+	}
+
+	var titles []string
+	if sp.TitleSelector != "" {
+		doc.Find(sp.TitleSelector).Each(func(_ int, s *goquery.Selection) {
+			titles = append(titles, strings.TrimSpace(s.Text()))
+		})
+	}
+	var eps []Episode
+	doc.Find(sp.URLSelector).Each(func(i int, s *goquery.Selection) {
+		link, ok := s.Attr("href")
+		if !ok {
+			link, ok = s.Attr("src")
+		}
+		ref, err := url.Parse(strings.TrimSpace(link))
+		if !ok || err != nil || ref.String() == "" {
+			return
+		}
+		title := strings.TrimSpace(s.Text())
+		if sp.TitleSelector != "" && i < len(titles) {
+			title = titles[i]
+		}
+		eps = append(eps, Episode{name: title, url: base.ResolveReference(ref).String()})
+	})
+
+	meta := PodMetadata{Title: strings.TrimSpace(doc.Find("title").First().Text()),
+		Description: strings.TrimSpace(doc.Find(`meta[name="description"]`).AttrOr("content", "")),
+		Link:        sp.url,
+		Image:       strings.TrimSpace(doc.Find(`meta[property="og:image"]`).AttrOr("content", ""))}
+	return newest(eps, limit), meta, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScrapeLongLine(t *testing.T) {
+	srv := serveFile(t, "longline.html")
+	limit := 0
+	pod := newPod(Config{Name: "Test", URL: srv.URL + "/arkiv/", Type: "scrape", URLSelector: "a.ep", MaxEpisodes: &limit})
+	eps, meta, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 203 {
+		t.Fatalf("got %d episodes, want 203", len(eps))
+	}
+	urls := make(map[string]string, len(eps))
+	for _, ep := range eps {
+		urls[ep.name] = ep.url
+	}
+	for name, want := range map[string]string{
+		"Avsnitt 1":   srv.URL + "/media/avsnitt-1.mp3",
+		"Avsnitt 2":   srv.URL + "/media/avsnitt-2.m4a",
+		"Avsnitt 200": srv.URL + "/media/avsnitt-200.m4a",
+		"Avsnitt 201": "https://example.com/media/avsnitt-201.mp3",
+		"Glued one":   "https://cdn.example.com/a.mp3?x=1",
+		"Glued two":   "https://cdn.example.com/b.m4a",
+	} {
+		if urls[name] != want {
+			t.Errorf("%s: got %q, want %q", name, urls[name], want)
+		}
+	}
+	want := PodMetadata{Title: "Arkivet", Description: "Alla avsnitt",
+		Link: srv.URL + "/arkiv/", Image: "https://example.com/cover.jpg"}
+	if meta != want {
+		t.Errorf("got %+v, want %+v", meta, want)
+	}
+}
+
+func TestScrapeNoMatch(t *testing.T) {
+	srv := serveFile(t, "nolinks.html")
+	pod := newPod(Config{Name: "Test", URL: srv.URL, Type: "scrape", URLSelector: "a.ep"})
+	eps, _, err := pod.fetch(context.Background())
+	if err != errNoEpisodes {
+		t.Errorf("got %d episodes and error %v, want errNoEpisodes", len(eps), err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SearchResult is an episode matching a search, with the pod it belongs to
+type SearchResult struct {
+	Pod string `json:"pod"`
+	TemplateEpisode
+}
+
+// search finds the episodes in pods whose title contains q, ignoring case
+func search(pods []TemplatePod, q string) []SearchResult {
+	res := []SearchResult{}
+	q = strings.ToLower(strings.TrimSpace(q))
+	if q == "" {
+		return res
+	}
+	for _, p := range pods {
+		for _, ep := range p.Episodes {
+			if strings.Contains(strings.ToLower(ep.Title), q) {
+				res = append(res, SearchResult{Pod: p.Name, TemplateEpisode: ep})
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Pod < res[j].Pod
+	})
+	return res
+}
+
+// searchHandler serves GET /search?q=, the episodes of every pod whose title
+// contains q. An empty q matches nothing.
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, search(GetPods(), r.URL.Query().Get("q")))
+}
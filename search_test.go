@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	resetStore(t)
+	kodsnack := newPod(Config{Name: "Kodsnack", URL: "https://example.com/kodsnack", Type: "rss"})
+	kodsnack.eps = []Episode{
+		{name: "Kodsnack 2 - Testning", url: "https://example.com/k2.mp3", description: "Om Go"},
+		{name: "Kodsnack 1 - Go", url: "https://example.com/k1.mp3"},
+	}
+	store.Add("Kodsnack", kodsnack)
+	gotime := newPod(Config{Name: "Go Time", URL: "https://example.com/gotime", Type: "rss"})
+	gotime.eps = []Episode{{name: "Generics in go", url: "https://example.com/g1.mp3", description: "Testning"}}
+	store.Add("Go Time", gotime)
+
+	tests := []struct {
+		name, q string
+		want    []string
+	}{
+		{"by title", "testning", []string{"kodsnack: Kodsnack 2 - Testning"}},
+		{"only in the description", "om go", nil},
+		{"ignoring case", "GO", []string{"go time: Generics in go", "kodsnack: Kodsnack 1 - Go"}},
+		{"surrounding space", "  generics ", []string{"go time: Generics in go"}},
+		{"empty", "", nil},
+		{"only space", "   ", nil},
+		{"no match", "rust", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			searchHandler(w, httptest.NewRequest("GET", "/search?q="+url.QueryEscape(tt.q), nil))
+			var res []SearchResult
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("%s: %s", err, w.Body)
+			}
+			if res == nil {
+				t.Errorf("got %s, want a list", w.Body)
+			}
+			var got []string
+			for _, r := range res {
+				got = append(got, r.Pod+": "+r.Title)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
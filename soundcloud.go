@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// soundCloudUser finds the id of the user in the html of a SoundCloud page,
+// it is in the app links of the page
+var soundCloudUser = regexp.MustCompile(`soundcloud://users:(\d+)`)
+
+// soundCloudFeed is the podcast feed SoundCloud publishes for every user
+const soundCloudFeed = "https://feeds.soundcloud.com/users/soundcloud:users:%s/sounds.rss"
+
+// soundCloudTracks lists the tracks of a user in the SoundCloud api
+const soundCloudTracks = "https://api.soundcloud.com/users/%s/tracks?client_id=%s&limit=%d"
+
+// SoundCloudParser implements the parser interface for the page of a
+// SoundCloud user or show. The id of the user is looked up on the first
+// fetch and remembered, the tracks are then read from the podcast feed of
+// the user or, when ClientID is set, from the api.
+type SoundCloudParser struct {
+	url string
+	// ClientID is the client id used for the api
+	ClientID string
+	// user is the id of the user once found
+	user string
+}
+
+// SoundCloudTrack is a track in the api, the urls need the client id
+type SoundCloudTrack struct {
+	ID           int64  `json:"id"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	CreatedAt    string `json:"created_at"`
+	Duration     int64  `json:"duration"` // milliseconds
+	Downloadable bool   `json:"downloadable"`
+	DownloadURL  string `json:"download_url"`
+	StreamURL    string `json:"stream_url"`
+	ArtworkURL   string `json:"artwork_url"`
+	Size         int64  `json:"original_content_size"`
+}
+
+// soundCloudTimeLayouts are the formats of created_at, the api has used both
+var soundCloudTimeLayouts = []string{"2006/01/02 15:04:05 -0700", time.RFC3339}
+
+// isSoundCloud reports whether u is on soundcloud.com
+func isSoundCloud(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == "soundcloud.com" || strings.HasSuffix(host, ".soundcloud.com")
+}
+
+// URLs extracts at most limit tracks of the user, tracks without any
+// downloadable audio are skipped
+func (sp *SoundCloudParser) URLs(ctx context.Context, v validators, limit int) (fetchResult, error) {
+	if sp.user == "" {
+		user, err := sp.resolve(ctx)
+		if err != nil {
+			return fetchResult{}, err
+		}
+		sp.user = user
+	}
+	if sp.ClientID != "" {
+		eps, err := sp.tracks(ctx, limit)
+		return fetchResult{eps: eps}, err
+	}
+	feed := fmt.Sprintf(soundCloudFeed, sp.user)
+	body, res, err := fetchStream(ctx, feed, v.header(feed))
+	if err != nil {
+		return res, err
+	}
+	defer body.Close()
+	// the feed moving is not the page moving
+	res.movedTo = ""
+	res.eps, res.meta, err = parseRss(body, limit)
+	return res, err
+}
+
+// resolve finds the id of the user of the page
+func (sp *SoundCloudParser) resolve(ctx context.Context) (string, error) {
+	bs, err := fetch(ctx, sp.url)
+	if err != nil {
+		return "", err
+	}
+	m := soundCloudUser.FindSubmatch(bs)
+	if m == nil {
+		return "", fmt.Errorf("no soundcloud user found on %s", sp.url)
+	}
+	return string(m[1]), nil
+}
+
+// tracks reads the tracks of the user from the api
+func (sp *SoundCloudParser) tracks(ctx context.Context, limit int) ([]Episode, error) {
+	n := limit
+	if n == 0 {
+		// the most the api returns at once
+		n = 200
+	}
+	u := fmt.Sprintf(soundCloudTracks, sp.user, url.QueryEscape(sp.ClientID), n)
+	bs, err := fetch(ctx, u)
+	if err != nil {
+		// the url has the client id in it
+		return nil, fmt.Errorf("fetching tracks of soundcloud user %s: %s", sp.user, strings.ReplaceAll(err.Error(), sp.ClientID, "..."))
+	}
+	return parseSoundCloudTracks(bs, sp.ClientID, limit)
+}
+
+// parseSoundCloudTracks extracts at most limit episodes from the track list
+// in bs, the media urls get clientID added
+func parseSoundCloudTracks(bs []byte, clientID string, limit int) ([]Episode, error) {
+	var tracks []SoundCloudTrack
+	err := json.Unmarshal(bs, &tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	eps := make([]Episode, 0, len(tracks))
+	for _, t := range tracks {
+		media := t.StreamURL
+		if t.Downloadable && t.DownloadURL != "" {
+			media = t.DownloadURL
+		}
+		if media == "" {
+			continue
+		}
+		eps = append(eps, Episode{name: t.Title,
+			url:         withClientID(media, clientID),
+			pubDate:     t.created(),
+			description: strings.TrimSpace(t.Description),
+			duration:    t.Duration / 1000,
+			length:      t.Size,
+			guid:        fmt.Sprint(t.ID),
+			image:       t.ArtworkURL})
+	}
+	return newest(eps, limit), nil
+}
+
+// created returns when the track was uploaded, an unknown format is the
+// zero time like in rss
+func (t SoundCloudTrack) created() time.Time {
+	for _, layout := range soundCloudTimeLayouts {
+		if c, err := time.Parse(layout, t.CreatedAt); err == nil {
+			return c
+		}
+	}
+	return time.Time{}
+}
+
+// withClientID adds the client id to the query of the media url u
+func withClientID(u, clientID string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	q := parsed.Query()
+	q.Set("client_id", clientID)
+	parsed.RawQuery = q.Encode()
+	return parsed.String()
+}
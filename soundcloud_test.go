@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// soundCloudServer answers for soundcloud.com and its api from testdata,
+// the requested hosts and paths are sent on seen
+func soundCloudServer(t *testing.T, seen chan<- string) {
+	serveHosts(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Get("X-Forwarded-Host") + r.URL.Path
+		switch r.Header.Get("X-Forwarded-Host") + r.URL.Path {
+		case "soundcloud.com/poddsallskapet":
+			http.ServeFile(w, r, "testdata/soundcloud-user.html")
+		case "api.soundcloud.com/users/12345/tracks":
+			if got := r.URL.Query().Get("client_id"); got != "hemligt" {
+				http.Error(w, "bad client id "+got, http.StatusUnauthorized)
+				return
+			}
+			http.ServeFile(w, r, "testdata/soundcloud-tracks.json")
+		case "feeds.soundcloud.com/users/soundcloud:users:12345/sounds.rss":
+			w.Write([]byte(rssFeed(2)))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestSoundCloudTracks(t *testing.T) {
+	seen := make(chan string, 10)
+	soundCloudServer(t, seen)
+	pod := newPod(Config{Name: "Test", URL: "https://soundcloud.com/poddsallskapet", Type: "soundcloud", ClientID: "hemligt"})
+	eps, _, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Episode{
+		{name: "Avsnitt 3",
+			url:         "https://api.soundcloud.com/tracks/1003/download?client_id=hemligt",
+			pubDate:     time.Date(2020, 1, 3, 12, 0, 0, 0, time.UTC),
+			description: "Det tredje avsnittet",
+			duration:    3600,
+			length:      57600000,
+			guid:        "1003",
+			image:       "https://i1.sndcdn.com/artworks-1003-large.jpg"},
+		{name: "Avsnitt 2",
+			url:      "https://api.soundcloud.com/tracks/1002/stream?client_id=hemligt",
+			pubDate:  time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC),
+			duration: 1800,
+			guid:     "1002"},
+	}
+	if len(eps) != len(want) {
+		t.Fatalf("got %q, want %q", titles(eps), titles(want))
+	}
+	for i := range want {
+		if !eps[i].pubDate.Equal(want[i].pubDate) {
+			t.Errorf("%s: published %s, want %s", want[i].name, eps[i].pubDate, want[i].pubDate)
+		}
+		eps[i].pubDate = want[i].pubDate
+		if !reflect.DeepEqual(eps[i], want[i]) {
+			t.Errorf("got %+v, want %+v", eps[i], want[i])
+		}
+	}
+
+	// the user is only looked up on the first fetch
+	if _, _, err := pod.fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(seen)
+	var got []string
+	for s := range seen {
+		got = append(got, s)
+	}
+	if want := []string{"soundcloud.com/poddsallskapet",
+		"api.soundcloud.com/users/12345/tracks", "api.soundcloud.com/users/12345/tracks"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("requested %q, want %q", got, want)
+	}
+}
+
+func TestSoundCloudFeed(t *testing.T) {
+	seen := make(chan string, 10)
+	soundCloudServer(t, seen)
+	pod := newPod(Config{Name: "Test", URL: "https://soundcloud.com/poddsallskapet", Type: "soundcloud"})
+	eps, _, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := titles(eps), []string{"Avsnitt 2", "Avsnitt 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if pod.url != "https://soundcloud.com/poddsallskapet" {
+		t.Errorf("the url of the pod changed to %s", pod.url)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// spotifyShow finds the show id in the path of a url such as
+// https://open.spotify.com/show/2mTUnDkuKUkhiueKcVWoP0
+var spotifyShow = regexp.MustCompile(`^/show/([0-9A-Za-z]+)`)
+
+// the endpoints of the client credentials flow and the show episodes
+const (
+	spotifyToken    = "https://accounts.spotify.com/api/token"
+	spotifyEpisodes = "https://api.spotify.com/v1/shows/%s/episodes?market=%s&limit=%d"
+)
+
+// spotifyMarket is the market episodes are listed for, the api requires one
+// without a user
+const spotifyMarket = "US"
+
+// spotifyMaxResults is the most episodes the api returns at once
+const spotifyMaxResults = 50
+
+// SpotifyParser implements the parser interface for Spotify shows using the
+// Web API. It authenticates with the client credentials flow and gets a new
+// token when the old one expires. The episodes link to the Spotify player
+// since the api has no audio files.
+type SpotifyParser struct {
+	url          string
+	ClientID     string
+	ClientSecret string
+
+	token   string
+	expires time.Time
+}
+
+// SpotifyEpisodes is a page of episodes of a show in the Web API
+type SpotifyEpisodes struct {
+	Items []struct {
+		ID           string `json:"id"`
+		Name         string `json:"name"`
+		Description  string `json:"description"`
+		HTML         string `json:"html_description"`
+		ReleaseDate  string `json:"release_date"`
+		DurationMS   int64  `json:"duration_ms"`
+		ExternalURLs struct {
+			Spotify string `json:"spotify"`
+		} `json:"external_urls"`
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	} `json:"items"`
+}
+
+// spotifyShowID returns the show id in a Spotify show url
+func spotifyShowID(u *url.URL) (string, bool) {
+	if strings.ToLower(u.Hostname()) != "open.spotify.com" {
+		return "", false
+	}
+	m := spotifyShow.FindStringSubmatch(u.Path)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// URLs lists at most limit episodes of the show, 50 when limit is 0
+func (sp *SpotifyParser) URLs(ctx context.Context, _ validators, limit int) (fetchResult, error) {
+	u, err := url.Parse(sp.url)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	show, ok := spotifyShowID(u)
+	if !ok {
+		return fetchResult{}, fmt.Errorf("no spotify show in %s", sp.url)
+	}
+	n := limit
+	if n == 0 || n > spotifyMaxResults {
+		n = spotifyMaxResults
+	}
+
+	var page SpotifyEpisodes
+	err = sp.get(ctx, fmt.Sprintf(spotifyEpisodes, show, spotifyMarket, n), &page)
+	var se *statusError
+	if errors.As(err, &se) && se.code == http.StatusUnauthorized {
+		// the token was revoked before it expired
+		sp.token = ""
+		err = sp.get(ctx, fmt.Sprintf(spotifyEpisodes, show, spotifyMarket, n), &page)
+	}
+	if err != nil {
+		return fetchResult{}, err
+	}
+
+	eps := make([]Episode, 0, len(page.Items))
+	for _, it := range page.Items {
+		if it.ExternalURLs.Spotify == "" {
+			continue
+		}
+		// an unparsable date is left as the zero time like in rss
+		released, _ := time.Parse("2006-01-02", it.ReleaseDate)
+		var image string
+		if len(it.Images) > 0 {
+			image = it.Images[0].URL
+		}
+		eps = append(eps, Episode{name: it.Name,
+			url:         it.ExternalURLs.Spotify,
+			pubDate:     released,
+			description: firstNonEmpty(it.HTML, it.Description),
+			duration:    it.DurationMS / 1000,
+			guid:        it.ID,
+			image:       image})
+	}
+	return fetchResult{eps: newest(eps, limit), meta: PodMetadata{Link: sp.url}}, nil
+}
+
+// get fetches the api url into v, with a token that is refreshed when it
+// has expired
+func (sp *SpotifyParser) get(ctx context.Context, u string, v interface{}) error {
+	if sp.token == "" || time.Now().After(sp.expires) {
+		err := sp.authenticate(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+sp.token)
+	return doJSON(req, v)
+}
+
+// authenticate gets a token with the client credentials flow
+func (sp *SpotifyParser) authenticate(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, spotifyToken, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(sp.ClientID, sp.ClientSecret)
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = doJSON(req, &tok)
+	if err != nil {
+		return fmt.Errorf("spotify authentication: %s", err.Error())
+	}
+	if tok.AccessToken == "" {
+		return fmt.Errorf("spotify authentication: no access token")
+	}
+	sp.token = tok.AccessToken
+	// a minute of slack so a token doesn't expire during a request
+	sp.expires = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return nil
+}
+
+// doJSON sends req with the shared client and decodes the json answer into
+// v, an error status is a statusError
+func doJSON(req *http.Request, v interface{}) error {
+	res, err := client.Do(req)
+	if isTimeout(err) {
+		return timeoutError(req.URL.String())
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		se := &statusError{url: req.URL.String(), code: res.StatusCode, status: res.Status}
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+			se.retryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		}
+		se.body, _ = io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		return se
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, *maxFeedSize)).Decode(v)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// spotifyServer is a mock of the Spotify accounts service and Web API
+type spotifyServer struct {
+	sync.Mutex
+	tokens int    // tokens handed out
+	valid  string // the token the api accepts
+}
+
+func (s *spotifyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	switch r.Header.Get("X-Forwarded-Host") + r.URL.Path {
+	case "accounts.spotify.com/api/token":
+		id, secret, ok := r.BasicAuth()
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" {
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		if !ok || id != "klient" || secret != "hemlighet" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusBadRequest)
+			return
+		}
+		s.tokens++
+		s.valid = fmt.Sprintf("token-%d", s.tokens)
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":3600}`, s.valid)
+	case "api.spotify.com/v1/shows/2mTUnDkuKUkhiueKcVWoP0/episodes":
+		if r.Header.Get("Authorization") != "Bearer "+s.valid {
+			http.Error(w, `{"error":{"status":401,"message":"The access token expired"}}`, http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, "testdata/spotify-episodes.json")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSpotify(t *testing.T) {
+	mock := &spotifyServer{}
+	serveHosts(t, mock.ServeHTTP)
+	pod := newPod(Config{Name: "Test", URL: "https://open.spotify.com/show/2mTUnDkuKUkhiueKcVWoP0", Type: "spotify",
+		ClientID: "klient", ClientSecret: "hemlighet"})
+	sp := pod.parser.(*SpotifyParser)
+
+	eps, meta, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Episode{
+		{name: "Avsnitt 2",
+			url:         "https://open.spotify.com/episode/ep2",
+			pubDate:     time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+			description: "<p>Det <b>andra</b> avsnittet</p>",
+			duration:    1800,
+			guid:        "ep2",
+			image:       "https://i.scdn.co/image/ep2-640"},
+		{name: "Avsnitt 1",
+			url:         "https://open.spotify.com/episode/ep1",
+			description: "Det första avsnittet",
+			duration:    3600,
+			guid:        "ep1"},
+	}
+	if !reflect.DeepEqual(eps, want) {
+		t.Errorf("got %+v, want %+v", eps, want)
+	}
+	if meta.Link != pod.url {
+		t.Errorf("got link %q", meta.Link)
+	}
+
+	tests := []struct {
+		name   string
+		before func()
+		tokens int
+	}{
+		{"token still valid", func() {}, 1},
+		{"token expired", func() { sp.expires = time.Now().Add(-time.Second) }, 2},
+		{"token revoked", func() {
+			mock.Lock()
+			mock.valid = "revoked"
+			mock.Unlock()
+		}, 3},
+	}
+	for _, tt := range tests {
+		tt.before()
+		if _, _, err := pod.fetch(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		mock.Lock()
+		tokens := mock.tokens
+		mock.Unlock()
+		if tokens != tt.tokens {
+			t.Errorf("%s: %d tokens, want %d", tt.name, tokens, tt.tokens)
+		}
+	}
+}
+
+func TestSpotifyBadCredentials(t *testing.T) {
+	serveHosts(t, (&spotifyServer{}).ServeHTTP)
+	pod := newPod(Config{Name: "Test", URL: "https://open.spotify.com/show/2mTUnDkuKUkhiueKcVWoP0", Type: "spotify",
+		ClientID: "klient", ClientSecret: "fel"})
+	_, _, err := pod.fetch(context.Background())
+	if err == nil || !strings.HasPrefix(err.Error(), "spotify authentication: ") {
+		t.Errorf("got %v, want an authentication error", err)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// podState is how a Pod is saved by a Store
+type podState struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// ConfigURL is the url the pod was configured with when the feed has
+	// moved since
+	ConfigURL   string `json:"config_url,omitempty"`
+	Type        string `json:"type"`
+	MaxEpisodes int    `json:"max_episodes"`
+	// the settings of the soundcloud, scrape, youtube and spotify types
+	ClientID      string `json:"client_id,omitempty"`
+	ClientSecret  string `json:"client_secret,omitempty"`
+	APIKey        string `json:"api_key,omitempty"`
+	URLSelector   string `json:"url_selector,omitempty"`
+	TitleSelector string `json:"title_selector,omitempty"`
+	BaseURL       string `json:"base_url,omitempty"`
+	// Added is set for pods added through the api or an opml import
+	Added bool `json:"added,omitempty"`
+
+	LastUpdate time.Time      `json:"last_update"`
+	Metadata   PodMetadata    `json:"metadata"`
+	Episodes   []episodeState `json:"episodes"`
+}
+
+// episodeState is how an Episode is saved by a Store
+type episodeState struct {
+	Title       string    `json:"title"`
+	Subtitle    string    `json:"subtitle,omitempty"`
+	URL         string    `json:"url"`
+	PubDate     time.Time `json:"pub_date"`
+	Description string    `json:"description,omitempty"`
+	Duration    int64     `json:"duration,omitempty"`
+	Length      int64     `json:"length,omitempty"`
+	GUID        string    `json:"guid,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Episode     int       `json:"episode,omitempty"`
+	Season      int       `json:"season,omitempty"`
+	MediaType   string    `json:"media_type,omitempty"`
+}
+
+// stateOf returns the state of pod, the caller must hold the store lock
+func stateOf(pod *Pod) podState {
+	ps := podState{Name: pod.name,
+		URL:           pod.url,
+		Type:          pod.kind,
+		MaxEpisodes:   pod.maxEpisodes,
+		ClientID:      pod.config.ClientID,
+		ClientSecret:  pod.config.ClientSecret,
+		APIKey:        pod.config.APIKey,
+		URLSelector:   pod.config.URLSelector,
+		TitleSelector: pod.config.TitleSelector,
+		BaseURL:       pod.config.BaseURL,
+		Added:         pod.added,
+		LastUpdate:    pod.lastUpdate,
+		Metadata:      pod.meta,
+		Episodes:      toEpisodeStates(pod.eps)}
+	if pod.config.URL != pod.url {
+		ps.ConfigURL = pod.config.URL
+	}
+	return ps
+}
+
+// FileStore keeps the pods in a json file, for -state
+type FileStore struct {
+	sync.Mutex
+	path string
+}
+
+// NewFileStore returns a FileStore for the file at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Save replaces the pod called name in the file with ps
+func (f *FileStore) Save(name string, ps podState) error {
+	return f.change(name, &ps)
+}
+
+// Delete removes the pod called name from the file
+func (f *FileStore) Delete(name string) error {
+	return f.change(name, nil)
+}
+
+// change replaces the pod called name with ps, or removes it when ps is
+// nil. The file is replaced atomically so a crash never leaves a half
+// written state behind.
+func (f *FileStore) change(name string, ps *podState) error {
+	f.Lock()
+	defer f.Unlock()
+	old, err := f.load()
+	if err != nil {
+		return err
+	}
+	key := strings.ToLower(name)
+	pods := old[:0]
+	for _, p := range old {
+		if strings.ToLower(p.Name) != key {
+			pods = append(pods, p)
+		}
+	}
+	if ps != nil {
+		pods = append(pods, *ps)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return strings.ToLower(pods[i].Name) < strings.ToLower(pods[j].Name)
+	})
+
+	bs, err := json.MarshalIndent(pods, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(bs)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// Load reads the pods in the file, a missing file is not an error
+func (f *FileStore) Load() ([]podState, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.load()
+}
+
+func (f *FileStore) load() ([]podState, error) {
+	bs, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data []podState
+	err = json.Unmarshal(bs, &data)
+	return data, err
+}
+
+// persisting is held from taking the state of a pod until it is saved, so
+// an older state never overwrites a newer one
+var persisting sync.Mutex
+
+// persist saves the state of pods to stateStore, errors are only logged
+func persist(pods ...*Pod) {
+	if _, ok := stateStore.(nopStore); ok {
+		return
+	}
+	persisting.Lock()
+	defer persisting.Unlock()
+	for _, pod := range pods {
+		store.RLock()
+		name, ps := pod.name, stateOf(pod)
+		store.RUnlock()
+		err := stateStore.Save(name, ps)
+		if err != nil {
+			slog.Error("saving state", "podcast", name, "err", err)
+		}
+	}
+}
+
+// unpersist removes the saved state of the pod called name, errors are only
+// logged
+func unpersist(name string) {
+	persisting.Lock()
+	defer persisting.Unlock()
+	err := stateStore.Delete(name)
+	if err != nil {
+		slog.Error("removing saved state", "podcast", name, "err", err)
+	}
+}
+
+// loadState restores the pods saved in stateStore. Saved pods that are not
+// configured are only added back when they were added through the api or
+// an opml import.
+func loadState() error {
+	data, err := stateStore.Load()
+	if err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+	for _, ps := range data {
+		key := strings.ToLower(ps.Name)
+		pod, ok := store.pods[key]
+		if !ok && !ps.Added {
+			slog.Info("dropping saved podcast that is not configured", "podcast", ps.Name)
+			continue
+		}
+		if !ok {
+			c := Config{Name: ps.Name,
+				URL:           firstNonEmpty(ps.ConfigURL, ps.URL),
+				Type:          ps.Type,
+				MaxEpisodes:   &ps.MaxEpisodes,
+				ClientID:      ps.ClientID,
+				ClientSecret:  ps.ClientSecret,
+				APIKey:        ps.APIKey,
+				URLSelector:   ps.URLSelector,
+				TitleSelector: ps.TitleSelector,
+				BaseURL:       ps.BaseURL}
+			err = c.validate()
+			if err != nil {
+				slog.Warn("skipping saved podcast", "err", err)
+				continue
+			}
+			pod = newPod(c)
+			pod.added = true
+			store.pods[key] = pod
+		}
+		if pod.config.URL != firstNonEmpty(ps.ConfigURL, ps.URL) {
+			// the config has changed since the state was saved
+			continue
+		}
+		pod.moveTo(ps.URL)
+		pod.lastUpdate = ps.LastUpdate
+		pod.meta = ps.Metadata
+		pod.eps = fromEpisodeStates(ps.Episodes)
+	}
+	return nil
+}
+
+func toEpisodeStates(eps []Episode) []episodeState {
+	es := make([]episodeState, len(eps))
+	for i, ep := range eps {
+		es[i] = episodeState{Title: ep.name,
+			Subtitle:    ep.subtitle,
+			URL:         ep.url,
+			PubDate:     ep.pubDate,
+			Description: ep.description,
+			Duration:    ep.duration,
+			Length:      ep.length,
+			GUID:        ep.guid,
+			Author:      ep.author,
+			Image:       ep.image,
+			Episode:     ep.episode,
+			Season:      ep.season,
+			MediaType:   ep.mediaType}
+	}
+	return es
+}
+
+func fromEpisodeStates(es []episodeState) []Episode {
+	eps := make([]Episode, len(es))
+	for i, e := range es {
+		eps[i] = Episode{name: e.Title,
+			subtitle:    e.Subtitle,
+			url:         e.URL,
+			pubDate:     e.PubDate,
+			description: e.Description,
+			duration:    e.Duration,
+			length:      e.Length,
+			guid:        e.GUID,
+			author:      e.Author,
+			image:       e.Image,
+			episode:     e.Episode,
+			season:      e.Season,
+			mediaType:   e.MediaType}
+	}
+	return eps
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setStateStore makes s the stateStore for the test
+func setStateStore(t *testing.T, s Store) {
+	t.Helper()
+	old := stateStore
+	stateStore = s
+	t.Cleanup(func() { stateStore = old })
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	resetStore(t)
+	setStateStore(t, NewFileStore(filepath.Join(t.TempDir(), "state.json")))
+	eps := []Episode{{name: "Avsnitt 1", url: "https://example.com/1.mp3",
+		pubDate: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), guid: "ep-1",
+		duration: 3600, length: 1234, episode: 1, season: 2, mediaType: "audio/mpeg"}}
+
+	configured := newPod(Config{Name: "Configured", URL: "https://example.com/configured", Type: "rss"})
+	configured.eps = eps
+	store.Add("Configured", configured)
+	added := newPod(Config{Name: "Added", URL: "https://example.com/added", Type: "rss"})
+	added.added = true
+	added.eps = eps
+	store.Add("Added", added)
+	removed := newPod(Config{Name: "Removed", URL: "https://example.com/removed", Type: "rss"})
+	removed.eps = eps
+	store.Add("Removed", removed)
+	persist(configured, added, removed)
+
+	// Removed has since been taken out of the config
+	store = NewPodStore()
+	store.Add("Configured", newPod(Config{Name: "Configured", URL: "https://example.com/configured", Type: "rss"}))
+	if err := loadState(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.Get("removed"); ok {
+		t.Error("a pod that is no longer configured was restored")
+	}
+	for _, name := range []string{"configured", "added"} {
+		pod, ok := store.Get(name)
+		if !ok {
+			t.Errorf("%s was not restored", name)
+			continue
+		}
+		if !reflect.DeepEqual(pod.eps, eps) {
+			t.Errorf("%s: got episodes %+v, want %+v", name, pod.eps, eps)
+		}
+		if want := name == "added"; pod.added != want {
+			t.Errorf("%s: added is %v, want %v", name, pod.added, want)
+		}
+	}
+}
+
+func TestLoadStateMissing(t *testing.T) {
+	resetStore(t)
+	setStateStore(t, NewFileStore(filepath.Join(t.TempDir(), "missing.json")))
+	if err := loadState(); err != nil {
+		t.Errorf("missing state file: %s", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PodStore holds the pods by lowercase name. Its lock guards the map as well
+// as the episodes and update state of every pod in it.
+type PodStore struct {
+	sync.RWMutex
+	pods map[string]*Pod
+
+	// updating keeps Update and UpdatePods from running alongside each
+	// other. Scheduled updates don't take it, they only hold the fetching
+	// lock of their pod.
+	updating sync.Mutex
+}
+
+// NewPodStore returns an empty store
+func NewPodStore() *PodStore {
+	return &PodStore{pods: make(map[string]*Pod)}
+}
+
+var store = NewPodStore()
+
+// shutdown is cancelled when the server shuts down, set by main. Updates
+// that outlive the request that started them run with it.
+var shutdown = context.Background()
+
+// Add stores p under name, replacing any pod with the same name
+func (s *PodStore) Add(name string, p *Pod) {
+	s.Lock()
+	s.pods[strings.ToLower(name)] = p
+	s.Unlock()
+}
+
+// Remove deletes the pod called name and reports whether it existed
+func (s *PodStore) Remove(name string) bool {
+	key := strings.ToLower(name)
+	s.Lock()
+	p, ok := s.pods[key]
+	delete(s.pods, key)
+	s.Unlock()
+	if ok {
+		metrics.forget(p.name)
+	}
+	return ok
+}
+
+// Get returns the pod called name
+func (s *PodStore) Get(name string) (*Pod, bool) {
+	s.RLock()
+	p, ok := s.pods[strings.ToLower(name)]
+	s.RUnlock()
+	return p, ok
+}
+
+// All returns a copy of the map of pods
+func (s *PodStore) All() map[string]*Pod {
+	s.RLock()
+	all := make(map[string]*Pod, len(s.pods))
+	for name, p := range s.pods {
+		all[name] = p
+	}
+	s.RUnlock()
+	return all
+}
+
+// due reports whether p should be updated at now, disabled pods never are
+// and rate limited pods not before the host asked for. Failing pods are
+// only updated once their backoff has passed unless force is set.
+// The caller must hold the store lock.
+func (p *Pod) due(now time.Time, force bool) bool {
+	if p.disabled {
+		slog.Debug("skipping disabled podcast", "podcast", p.name)
+		return false
+	}
+	if p.rateLimited.After(now) {
+		slog.Debug("rate limited", "podcast", p.name, "until", p.rateLimited)
+		return false
+	}
+	if !force && p.nextAttempt.After(now) {
+		slog.Debug("backing off", "podcast", p.name, "until", p.nextAttempt)
+		return false
+	}
+	return true
+}
+
+// Update all pods, pods left when ctx is cancelled are logged and skipped.
+// Failing pods are skipped until their backoff has passed unless force is set.
+func (s *PodStore) Update(ctx context.Context, force bool) {
+	s.updating.Lock()
+	defer s.updating.Unlock()
+
+	// a minute of slack so ticker drift doesn't skip a pod that is due
+	due := time.Now().Add(time.Minute)
+	s.RLock()
+	all := make([]*Pod, 0, len(s.pods))
+	for _, pod := range s.pods {
+		if pod.due(due, force) {
+			all = append(all, pod)
+		}
+	}
+	s.RUnlock()
+
+	slog.Debug("updating podcasts", "count", len(all))
+	s.updatePods(ctx, all)
+}
+
+// UpdatePods updates the given pods, waiting for any Update in progress
+// first so the two don't fetch alongside each other
+func (s *PodStore) UpdatePods(ctx context.Context, pods []*Pod) {
+	s.updating.Lock()
+	defer s.updating.Unlock()
+	s.updatePods(ctx, pods)
+}
+
+// updatePods updates the given pods with a pool of -workers goroutines
+// taking the pods from a queue
+func (s *PodStore) updatePods(ctx context.Context, all []*Pod) {
+	queue := make(chan *Pod)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers && i < len(all); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pod := range queue {
+				s.updatePod(ctx, pod)
+			}
+		}()
+	}
+	for _, pod := range all {
+		queue <- pod
+	}
+	close(queue)
+	wg.Wait()
+
+	if ctx.Err() == nil {
+		persist(all...)
+	}
+}
+
+// updatePod fetches pod and records the result. The feed is fetched without
+// holding the store lock, only one fetch of a pod runs at a time.
+func (s *PodStore) updatePod(ctx context.Context, pod *Pod) {
+	pod.fetching.Lock()
+	defer pod.fetching.Unlock()
+	if ctx.Err() != nil {
+		slog.Warn("update interrupted", "podcast", pod.name, "url", pod.url)
+		return
+	}
+	start := time.Now()
+	eps, meta, err := pod.fetch(ctx)
+	if ctx.Err() != nil {
+		slog.Warn("update interrupted", "podcast", pod.name, "url", pod.url)
+		return
+	}
+	s.Lock()
+	pod.record(eps, meta, err, time.Now())
+	count := len(pod.eps)
+	s.Unlock()
+	metrics.observe(pod.name, time.Since(start), count, err)
+	metrics.lastUpdate.SetToCurrentTime()
+	if err == errNotModified {
+		slog.Debug("not modified", "podcast", pod.name, "duration", time.Since(start))
+	} else if isPartial(err) {
+		slog.Warn("updated with errors", "podcast", pod.name, "url", pod.url, "duration", time.Since(start), "err", err)
+	} else if err != nil {
+		slog.Error("update failed", "podcast", pod.name, "url", pod.url, "duration", time.Since(start), "err", err)
+	} else {
+		slog.Debug("updated", "podcast", pod.name, "episodes", count, "duration", time.Since(start))
+	}
+}
+
+// maxJitter is the most the first scheduled update of a pod is shifted by
+const maxJitter = 5 * time.Minute
+
+// jitter returns a random duration of at most maxJitter, or a quarter of
+// the interval d when that is shorter, in either direction
+func jitter(d time.Duration) time.Duration {
+	n := maxJitter
+	if n > d/4 {
+		n = d / 4
+	}
+	return time.Duration(rand.Int63n(int64(2*n)+1)) - n
+}
+
+// updateQueue starts -workers goroutines updating the pods sent on the
+// returned queue until ctx is cancelled, so scheduled pods that are due at
+// the same time don't all fetch at once
+func (s *PodStore) updateQueue(ctx context.Context, wg *sync.WaitGroup) chan<- *Pod {
+	queue := make(chan *Pod)
+	for range *workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case pod := <-queue:
+					s.updatePod(ctx, pod)
+					if ctx.Err() == nil {
+						persist(pod)
+					}
+				}
+			}
+		}()
+	}
+	return queue
+}
+
+// schedule sends pod to queue every d until ctx is cancelled. The first
+// update is shifted by a random jitter so the pods are not all due at once.
+func (s *PodStore) schedule(ctx context.Context, pod *Pod, d time.Duration, queue chan<- *Pod) {
+	first := time.NewTimer(d + jitter(d))
+	select {
+	case <-ctx.Done():
+		first.Stop()
+		return
+	case <-first.C:
+	}
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		// a minute of slack so ticker drift doesn't skip a pod that is due
+		s.RLock()
+		due := pod.due(time.Now().Add(time.Minute), false)
+		s.RUnlock()
+		if due {
+			select {
+			case <-ctx.Done():
+				return
+			case queue <- pod:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// checkPods updates every pod and removes the ones whose feed could not be
+// fetched or parsed, for -check-feeds
+func checkPods(ctx context.Context) {
+	store.Update(ctx, true)
+	failed := make(map[string]error)
+	store.RLock()
+	for _, pod := range store.pods {
+		if pod.lastError != nil && !isPartial(pod.lastError) {
+			failed[pod.name] = pod.lastError
+		}
+	}
+	store.RUnlock()
+	for name, err := range failed {
+		slog.Error("leaving out podcast", "podcast", name, "err", err)
+		store.Remove(name)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpdateCancelled(t *testing.T) {
+	resetStore(t)
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	for _, name := range []string{"a", "b", "c"} {
+		store.Add(name, newPod(Config{Name: name, URL: srv.URL + "/" + name, Type: "rss"}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.Update(ctx, true)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Update did not return after the context was cancelled")
+	}
+	for name, pod := range store.All() {
+		if pod.lastError != nil || pod.failures != 0 {
+			t.Errorf("%s: interrupted update was recorded", name)
+		}
+	}
+}
+
+func TestIndexDuringSlowFetch(t *testing.T) {
+	resetStore(t)
+	release := make(chan struct{})
+	requested := make(chan struct{}, 1)
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		fmt.Fprint(w, rssFeed(1))
+	})
+	store.Add("slow", newPod(Config{Name: "slow", URL: srv.URL, Type: "rss"}))
+
+	done := make(chan struct{})
+	go func() {
+		store.Update(context.Background(), true)
+		close(done)
+	}()
+	<-requested
+
+	served := make(chan struct{})
+	go func() {
+		index(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		apiPods(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/pods", nil))
+		close(served)
+	}()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Error("index blocked while a feed was being fetched")
+	}
+	close(release)
+	<-done
+
+	pod, _ := store.Get("slow")
+	store.RLock()
+	defer store.RUnlock()
+	if len(pod.eps) != 1 {
+		t.Errorf("got %d episodes after the fetch, want 1", len(pod.eps))
+	}
+}
+
+func TestFailedUpdateKeepsEpisodes(t *testing.T) {
+	resetStore(t)
+	var items atomic.Int32
+	items.Store(2)
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, rssFeed(int(items.Load())))
+	})
+	store.Add("Test", newPod(Config{Name: "Test", URL: srv.URL, Type: "rss"}))
+	pod, _ := store.Get("Test")
+
+	store.Update(context.Background(), true)
+	store.RLock()
+	if pod.lastError != nil || len(pod.eps) != 2 {
+		t.Fatalf("first update: %d episodes, err %v", len(pod.eps), pod.lastError)
+	}
+	store.RUnlock()
+
+	// the feed comes back empty
+	items.Store(0)
+	store.Update(context.Background(), true)
+	store.RLock()
+	defer store.RUnlock()
+	if pod.lastError == nil {
+		t.Error("an empty feed was not an error")
+	}
+	if pod.failures != 1 {
+		t.Errorf("got %d failures, want 1", pod.failures)
+	}
+	if got, want := titles(pod.eps), []string{"Avsnitt 2", "Avsnitt 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("episodes after the failed update %q, want %q", got, want)
+	}
+}
+
+func TestNotModifiedKeepsEpisodes(t *testing.T) {
+	resetStore(t)
+	var requests, conditional atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") != "" {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2020 12:00:00 GMT")
+		// a feed that is parsed again would have more episodes
+		fmt.Fprint(w, rssFeed(int(requests.Load())+1))
+	})
+	store.Add("Test", newPod(Config{Name: "Test", URL: srv.URL, Type: "rss"}))
+	pod, _ := store.Get("Test")
+
+	store.Update(context.Background(), true)
+	store.RLock()
+	first := pod.lastUpdate
+	store.RUnlock()
+	time.Sleep(10 * time.Millisecond)
+	store.Update(context.Background(), true)
+
+	if n := conditional.Load(); n != 1 {
+		t.Fatalf("%d conditional requests of %d, want 1", n, requests.Load())
+	}
+	store.RLock()
+	defer store.RUnlock()
+	if got, want := titles(pod.eps), []string{"Avsnitt 2", "Avsnitt 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("episodes after a 304 %q, want %q", got, want)
+	}
+	if pod.lastError != nil || pod.failures != 0 {
+		t.Errorf("a 304 counted as a failure: %v", pod.lastError)
+	}
+	if !pod.lastUpdate.After(first) {
+		t.Error("a 304 did not count as an update")
+	}
+}
+
+func TestWorkersUpdateConcurrently(t *testing.T) {
+	resetStore(t)
+	old := *workers
+	*workers = 4
+	t.Cleanup(func() { *workers = old })
+
+	const delay = 50 * time.Millisecond
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		fmt.Fprint(w, rssFeed(1))
+	})
+	for i := range 20 {
+		name := fmt.Sprintf("pod%d", i)
+		store.Add(name, newPod(Config{Name: name, URL: srv.URL + "/" + name, Type: "rss"}))
+	}
+
+	start := time.Now()
+	store.Update(context.Background(), true)
+	took := time.Since(start)
+
+	// 20 pods on 4 workers take 5 rounds of delay, updating them one at a
+	// time takes 20, half that leaves room for a slow machine
+	if limit := 20 * delay / 2; took >= limit {
+		t.Errorf("updating 20 pods took %s, want less than %s", took, limit)
+	}
+	for name, pod := range store.All() {
+		if len(pod.eps) != 1 {
+			t.Errorf("%s: %d episodes, want 1", name, len(pod.eps))
+		}
+	}
+}
+
+// captureLogs sends the default logger to the returned buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	resetStore(t)
+	setRetries(t, 1)
+	old := *maxFailures
+	*maxFailures = 3
+	t.Cleanup(func() { *maxFailures = old })
+	logs := captureLogs(t)
+
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	feed := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, rssFeed(2))
+	})
+	pod := newPod(Config{Name: "Trasig", URL: feed.URL, Type: "rss"})
+	store.Add("trasig", pod)
+
+	for range 5 {
+		store.Update(context.Background(), true)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("%d requests, want 3 before the podcast is disabled", got)
+	}
+	if !pod.disabled || pod.failures != 3 {
+		t.Fatalf("disabled %v after %d failures, want disabled after 3", pod.disabled, pod.failures)
+	}
+	if !strings.Contains(logs.String(), `level=WARN msg="podcast disabled" podcast=Trasig failures=3`) {
+		t.Errorf("the trip was not logged as a warning:\n%s", logs)
+	}
+
+	healthy.Store(true)
+	srv := apiServer(t)
+	if code := do(t, http.MethodPost, srv.URL+"/api/podcasts/trasig/enable", "", ""); code != http.StatusOK {
+		t.Fatalf("enable: %d", code)
+	}
+	if pod.disabled || pod.failures != 0 {
+		t.Errorf("disabled %v with %d failures after enabling", pod.disabled, pod.failures)
+	}
+	if !strings.Contains(logs.String(), `level=WARN msg="podcast enabled again" podcast=Trasig`) {
+		t.Errorf("the recovery was not logged as a warning:\n%s", logs)
+	}
+	store.Update(context.Background(), false)
+	if got := requests.Load(); got != 4 {
+		t.Errorf("%d requests, want the enabled podcast to be fetched again", got)
+	}
+	if len(pod.eps) != 2 || pod.lastError != nil {
+		t.Errorf("got %d episodes and error %v after recovering", len(pod.eps), pod.lastError)
+	}
+}
+
+func TestScheduledUpdatesShareWorkers(t *testing.T) {
+	resetStore(t)
+	old := *workers
+	*workers = 2
+	t.Cleanup(func() { *workers = old })
+
+	var inFlight, most, fetched atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, rssFeed(1))
+		fetched.Add(1)
+	})
+	var pods []*Pod
+	for i := range 6 {
+		name := fmt.Sprintf("pod%d", i)
+		pod := newPod(Config{Name: name, URL: srv.URL + "/" + name, Type: "rss"})
+		store.Add(name, pod)
+		pods = append(pods, pod)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	queue := store.updateQueue(ctx, &wg)
+	for _, pod := range pods {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.schedule(ctx, pod, 20*time.Millisecond, queue)
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for fetched.Load() < int32(len(pods)) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	if n := fetched.Load(); n < int32(len(pods)) {
+		t.Fatalf("only %d fetches", n)
+	}
+	if n := most.Load(); n > 2 {
+		t.Errorf("%d fetches ran at once, want at most -workers 2", n)
+	}
+}
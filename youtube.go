@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// youtubeChannel finds the channel id in the path of a channel url such as
+// https://www.youtube.com/channel/UCxxxxxxxxxxxxxxxxxxxxxx
+var youtubeChannel = regexp.MustCompile(`^/channel/(UC[\w-]+)`)
+
+// youtubeSearch lists the latest videos of a channel in the youtube data api
+const youtubeSearch = "https://www.googleapis.com/youtube/v3/search?part=snippet&type=video&order=date&channelId=%s&maxResults=%d"
+
+// youtubeKeyHeader carries the api key, so it is not in the url
+const youtubeKeyHeader = "X-Goog-Api-Key"
+
+// the number of videos listed when there is no limit, and the most the
+// search returns at once
+const (
+	youtubeDefaultResults = 10
+	youtubeMaxResults     = 50
+)
+
+// YoutubeParser implements the parser interface for youtube channels using
+// the youtube data api. The episodes link to the videos.
+type YoutubeParser struct {
+	url string
+	// APIKey is the key for the data api
+	APIKey string
+}
+
+// YoutubeSearch is the answer of the search endpoint of the data api
+type YoutubeSearch struct {
+	Items []struct {
+		ID struct {
+			VideoID string `json:"videoId"`
+		} `json:"id"`
+		Snippet struct {
+			PublishedAt  time.Time `json:"publishedAt"`
+			Title        string    `json:"title"`
+			Description  string    `json:"description"`
+			ChannelTitle string    `json:"channelTitle"`
+			Thumbnails   map[string]struct {
+				URL string `json:"url"`
+			} `json:"thumbnails"`
+		} `json:"snippet"`
+	} `json:"items"`
+}
+
+// youtubeChannelID returns the channel id in a youtube channel url
+func youtubeChannelID(u *url.URL) (string, bool) {
+	host := strings.ToLower(u.Hostname())
+	if host != "youtube.com" && !strings.HasSuffix(host, ".youtube.com") {
+		return "", false
+	}
+	m := youtubeChannel.FindStringSubmatch(u.Path)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// isQuotaError reports whether err is the data api refusing requests
+// because the quota of the key is used up
+func isQuotaError(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == 403 &&
+		(bytes.Contains(se.body, []byte("quotaExceeded")) || bytes.Contains(se.body, []byte("dailyLimitExceeded")))
+}
+
+// URLs lists the latest limit videos of the channel, 10 when limit is 0.
+// When the quota of the key is used up the episodes are left as they are.
+func (yp *YoutubeParser) URLs(ctx context.Context, _ validators, limit int) (fetchResult, error) {
+	u, err := url.Parse(yp.url)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	channel, ok := youtubeChannelID(u)
+	if !ok {
+		return fetchResult{}, fmt.Errorf("no youtube channel in %s", yp.url)
+	}
+	n := limit
+	if n == 0 {
+		n = youtubeDefaultResults
+	}
+	if n > youtubeMaxResults {
+		n = youtubeMaxResults
+	}
+
+	header := http.Header{}
+	header.Set(youtubeKeyHeader, yp.APIKey)
+	bs, _, err := fetchContent(ctx, fmt.Sprintf(youtubeSearch, channel, n), header)
+	if isQuotaError(err) {
+		slog.Warn("youtube quota exceeded, keeping the episodes", "url", yp.url)
+		return fetchResult{}, errNotModified
+	}
+	if err != nil {
+		return fetchResult{}, fmt.Errorf("searching youtube channel %s: %s", channel, err.Error())
+	}
+	eps, meta, err := parseYoutubeSearch(bs, limit)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	meta.Link = yp.url
+	return fetchResult{eps: eps, meta: meta}, nil
+}
+
+// parseYoutubeSearch extracts at most limit episodes from the search result
+// in bs, the metadata only has the title of the channel
+func parseYoutubeSearch(bs []byte, limit int) ([]Episode, PodMetadata, error) {
+	var res YoutubeSearch
+	err := json.Unmarshal(bs, &res)
+	if err != nil {
+		return nil, PodMetadata{}, err
+	}
+
+	var meta PodMetadata
+	eps := make([]Episode, 0, len(res.Items))
+	for _, it := range res.Items {
+		if it.ID.VideoID == "" {
+			continue
+		}
+		sn := it.Snippet
+		if meta.Title == "" {
+			meta.Title = html.UnescapeString(sn.ChannelTitle)
+		}
+		// the api escapes the html in titles and descriptions
+		eps = append(eps, Episode{name: html.UnescapeString(sn.Title),
+			url:         "https://www.youtube.com/watch?v=" + it.ID.VideoID,
+			pubDate:     sn.PublishedAt,
+			description: html.UnescapeString(sn.Description),
+			guid:        it.ID.VideoID,
+			author:      html.UnescapeString(sn.ChannelTitle),
+			image:       sn.Thumbnails["high"].URL,
+			mediaType:   "video/youtube"})
+	}
+	return newest(eps, limit), meta, nil
+}
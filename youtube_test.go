@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestYoutubeKeyInHeader(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	serveHosts(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.String())
+		mu.Unlock()
+		if r.Header.Get("X-Goog-Api-Key") != "hemlig-nyckel" {
+			http.Error(w, `{"error":{"code":403,"message":"bad key"}}`, http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("channelId") == "UCbroken" {
+			http.Error(w, "boom", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"items":[{"id":{"videoId":"abc"},"snippet":{"publishedAt":"2020-01-02T12:00:00Z","title":"Avsnitt &amp; video","channelTitle":"Kanalen"}}]}`)
+	})
+
+	pod := newPod(Config{Name: "Test", URL: "https://www.youtube.com/channel/UCtest", Type: "youtube", APIKey: "hemlig-nyckel"})
+	eps, meta, err := pod.fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := titles(eps), []string{"Avsnitt & video"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if meta.Title != "Kanalen" {
+		t.Errorf("got title %q", meta.Title)
+	}
+
+	broken := newPod(Config{Name: "Broken", URL: "https://www.youtube.com/channel/UCbroken", Type: "youtube", APIKey: "hemlig-nyckel"})
+	_, _, err = broken.fetch(context.Background())
+	if err == nil {
+		t.Fatal("a failed search is not an error")
+	}
+	if strings.Contains(err.Error(), "hemlig") {
+		t.Errorf("the key is in the error %q", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, u := range requested {
+		if strings.Contains(u, "hemlig") || strings.Contains(u, "key=") {
+			t.Errorf("the key is in the url %s", u)
+		}
+	}
+}